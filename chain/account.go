@@ -0,0 +1,131 @@
+package chain
+
+import (
+	"database/sql"
+	"eastnode/types"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// GetTxFee returns TX_FEE from env, the amount debited from the signer of every transaction
+func GetTxFee() uint64 {
+	fee, err := strconv.ParseUint(os.Getenv("TX_FEE"), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return fee
+}
+
+func (c *Chain) GetBalance(account string) (uint64, error) {
+	var balance uint64
+
+	err := c.Store.Instance.QueryRow("SELECT balance FROM balances WHERE account = ?", account).Scan(&balance)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+func (c *Chain) setBalance(account string, balance uint64) error {
+	_, err := c.Store.Instance.Exec(
+		`REPLACE INTO balances (account, balance)
+			VALUES (?, ?);`, account, balance,
+	)
+
+	return err
+}
+
+func (c *Chain) Credit(account string, amount uint64) error {
+	balance, err := c.GetBalance(account)
+	if err != nil {
+		return err
+	}
+
+	if balance > math.MaxUint64-amount {
+		return errors.New("balance overflow")
+	}
+
+	return c.setBalance(account, balance+amount)
+}
+
+func (c *Chain) Debit(account string, amount uint64) error {
+	balance, err := c.GetBalance(account)
+	if err != nil {
+		return err
+	}
+
+	if balance < amount {
+		return fmt.Errorf("insufficient balance: %d < %d", balance, amount)
+	}
+
+	return c.setBalance(account, balance-amount)
+}
+
+// CheckFee returns an error if account can't pay the transaction fee
+func (c *Chain) CheckFee(account string) error {
+	fee := GetTxFee()
+	if fee == 0 {
+		return nil
+	}
+
+	balance, err := c.GetBalance(account)
+	if err != nil {
+		return err
+	}
+
+	if balance < fee {
+		return fmt.Errorf("insufficient balance for fee: %d < %d", balance, fee)
+	}
+
+	return nil
+}
+
+// isAccount returns true if account is a hex encoded public key, the identity of tx.Signer
+func isAccount(account string) bool {
+	pubKey, err := hex.DecodeString(account)
+	if err != nil {
+		return false
+	}
+
+	_, err = btcec.ParsePubKey(pubKey)
+	return err == nil
+}
+
+// ProcessTransfer moves an amount from tx.Signer to tx.Receiver
+// Args: [amount]
+func (c *Chain) ProcessTransfer(tx types.Transaction, action types.Action) (string, error) {
+	if len(action.Args) != 1 {
+		return "", errors.New("transfer requires an amount")
+	}
+
+	amount, err := strconv.ParseUint(action.Args[0], 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid amount: %w", err)
+	}
+
+	// balances are keyed by signer, anything else couldn't be spent
+	if !isAccount(tx.Receiver) {
+		return "", errors.New("transfer receiver must be a public key")
+	}
+
+	if err := c.Debit(tx.Signer, amount); err != nil {
+		return "", err
+	}
+
+	if err := c.Credit(tx.Receiver, amount); err != nil {
+		return "", err
+	}
+
+	return action.Args[0], nil
+}
@@ -12,6 +12,9 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/btcsuite/btcd/btcutil/bech32"
@@ -20,17 +23,30 @@ import (
 	bolt "go.etcd.io/bbolt"
 )
 
-type GenesisAccount struct {
-	Account string
-	Value   string
-}
+const DEFAULT_CHAIN_ID_PREFIX = "Eastblue"
 
 type Chain struct {
-	// TODO: use sync.Mutex https://go.dev/tour/concurrency/9
-	Locked      bool
+	// held while a block is produced, RPC calls and the block producer run concurrently
+	mu          sync.Mutex
 	Store       *store.Store
 	Mempool     *Mempool
 	WasmRuntime *runtime.WasmRuntime
+	// credited by the genesis block
+	GenesisAccounts []GenesisAccount
+}
+
+// GetChainID returns CHAIN_ID from env, defaulting to Eastblue-<NETWORK>
+func GetChainID() string {
+	if chainID := os.Getenv("CHAIN_ID"); chainID != "" {
+		return chainID
+	}
+
+	network := os.Getenv("NETWORK")
+	if network == "" {
+		network = "regtest"
+	}
+
+	return fmt.Sprintf("%s-%s", DEFAULT_CHAIN_ID_PREFIX, network)
 }
 
 func (c *Chain) Init(indexerDbRepo *indexerDb.DBRepository) *Chain {
@@ -42,6 +58,12 @@ func (c *Chain) Init(indexerDbRepo *indexerDb.DBRepository) *Chain {
 
 	c.WasmRuntime = &runtime.WasmRuntime{Store: *store.GetInstance(store.SmartIndexDB), IndexerDbRepo: indexerDbRepo}
 
+	genesisAccounts, err := LoadGenesisAccounts()
+	if err != nil {
+		log.Panicln(err)
+	}
+	c.GenesisAccounts = genesisAccounts
+
 	log.Printf("[+] chain initialized")
 
 	c.ProduceBlock()
@@ -49,16 +71,6 @@ func (c *Chain) Init(indexerDbRepo *indexerDb.DBRepository) *Chain {
 	return c
 }
 
-func (c *Chain) Lock() {
-	c.Locked = true
-}
-
-func (c *Chain) Unlock() {
-	c.Locked = false
-
-	c.ProduceBlock()
-}
-
 func (c *Chain) Genesis() bool {
 	genesisInit := true
 
@@ -141,7 +153,7 @@ func (c *Chain) GetNonce(pubKey string) uint64 {
 
 func (c *Chain) CheckTx(signedTx types.SignedTransaction) error {
 	// check signature valid
-	verified := signedTx.IsValid()
+	verified := signedTx.IsValid() && signedTx.CosignaturesValid()
 
 	// unpack signedTx
 	inputTx := signedTx.Unpack()
@@ -163,18 +175,52 @@ func (c *Chain) CheckTx(signedTx types.SignedTransaction) error {
 		return errors.New("invalid nonce")
 	})
 
-	if verified && err == nil {
-		return nil
-	}
-
 	if !verified {
 		return errors.New("invalid signature")
 	}
 
-	return err
+	if err != nil {
+		return err
+	}
+
+	if err := c.CheckFee(inputTx.Signer); err != nil {
+		return err
+	}
+
+	// the tx will be included in the next block at the earliest
+	return c.CheckTxInclusion(inputTx, c.GetBlockHeight()+1)
 }
 
+// CheckTxInclusion validates the replay protection fields of tx for a block at blockHeight
+func (c *Chain) CheckTxInclusion(tx types.Transaction, blockHeight uint64) error {
+	if tx.ChainID != GetChainID() {
+		return errors.New("invalid chain id")
+	}
+
+	if tx.ExpiryHeight != 0 && tx.ExpiryHeight < blockHeight {
+		return errors.New("transaction expired")
+	}
+
+	return nil
+}
+
+// ProduceBlock produces blocks until the mempool is empty, a block that only runs block hooks
+// is produced once per call so hooks far behind catch up over the block producer ticks.
+// The mutex is released between blocks so RPC calls aren't blocked for long
 func (c *Chain) ProduceBlock() error {
+	for {
+		c.mu.Lock()
+		produced, err := c.produceBlock()
+		c.mu.Unlock()
+
+		if err != nil || !produced || c.Mempool.Length() == 0 {
+			return err
+		}
+	}
+}
+
+// produceBlock returns false if there was nothing to produce
+func (c *Chain) produceBlock() (bool, error) {
 	// process all tx
 	transactions := []types.SignedTransaction{}
 
@@ -184,20 +230,7 @@ func (c *Chain) ProduceBlock() error {
 		log.Println("Processing Genesis Block")
 
 		// get genesis config
-		genAccounts := []GenesisAccount{
-			{
-				Account: "bc1ph02hv4dc9afhcycs04vtawkmmm055j3g39w7mqur6d2x5ng4dgmshavfvj",
-				Value:   "1000",
-			},
-			{
-				Account: "bc1pkskdm7qk0z4gr8cgy38ysa00gyftj364gmf3uruse80c6gzunf6s0ywcsh",
-				Value:   "5000",
-			},
-			{
-				Account: "bc1pkskdm7qk0z4gr8cgy38ysa00gyftj364gmf3uruse80c6gzunf6s0ywcsh",
-				Value:   "10000",
-			},
-		}
+		genAccounts := c.GenesisAccounts
 
 		genesisTime := time.Now().UnixMilli()
 
@@ -219,6 +252,7 @@ func (c *Chain) ProduceBlock() error {
 				Signer:   "genesis",
 				Receiver: v.Account,
 				Actions:  hex.EncodeToString(actionsPacked),
+				ChainID:  GetChainID(),
 			}
 			gTxPacked, err := borsh.Serialize(gTx)
 			if err != nil {
@@ -239,6 +273,15 @@ func (c *Chain) ProduceBlock() error {
 			if err != nil {
 				panic(err)
 			}
+
+			genesisValue, err := strconv.ParseUint(v.Value, 10, 64)
+			if err != nil {
+				panic(err)
+			}
+
+			if err := c.Credit(v.Account, genesisValue); err != nil {
+				panic(err)
+			}
 			transactions = append(transactions, gSignedTx)
 			txMerkleTree = append(txMerkleTree, types.MerkleTreeContent{
 				Value: gSignedTx.ID,
@@ -261,7 +304,7 @@ func (c *Chain) ProduceBlock() error {
 		}
 
 		newBlock.Header = types.BlockHeader{
-			ChainID:     "Eastblue",
+			ChainID:     GetChainID(),
 			Height:      0,
 			LastBlockID: []byte(""),
 			DataHash:    t.MerkleRoot(),
@@ -304,8 +347,15 @@ func (c *Chain) ProduceBlock() error {
 
 		pendingTx := c.Mempool.Length()
 
-		if pendingTx == 0 || c.Locked {
-			return nil
+		// pending hooks and the hooks of this block use the same tip
+		bitcoinHeight, bitcoinHash, err := c.HookTip()
+		if err != nil {
+			log.Println(err)
+			bitcoinHeight, bitcoinHash = -1, ""
+		}
+
+		if pendingTx == 0 && !c.HasPendingBlockHooks(bitcoinHeight) {
+			return false, nil
 		}
 
 		pendingTx = uint64(math.Min(float64(pendingTx), float64(10)))
@@ -315,8 +365,6 @@ func (c *Chain) ProduceBlock() error {
 		c.doltDeleteBranch("working_branch")
 		c.doltCreateNewBranch("working_branch")
 
-		c.Lock()
-
 		lastBlock := c.GetBlock(blockHeight)
 
 		txMerkleTree := []merkletree.Content{}
@@ -329,6 +377,7 @@ func (c *Chain) ProduceBlock() error {
 			pSignedTx := c.Mempool.Get(i) // ensure pushback
 
 			txUnpacked := pSignedTx.Unpack()
+			signers := pSignedTx.Signers()
 
 			parsedActions := new([]types.Action)
 			utils.DecodeHexAndBorshDeserialize(parsedActions, txUnpacked.Actions)
@@ -337,16 +386,37 @@ func (c *Chain) ProduceBlock() error {
 			statuses := types.JsonArray{Array: []string{}}
 			logs := types.JsonArray{Array: []string{}}
 
+			// the tx might have expired while waiting in the mempool
+			err := c.CheckTxInclusion(txUnpacked, blockHeight+1)
+
+			// fee is committed before the actions so it stays debited if an action fails
+			if fee := GetTxFee(); err == nil && fee > 0 {
+				err = c.Debit(txUnpacked.Signer, fee)
+				if err == nil {
+					c.doltAddAndCommit(fmt.Sprintf("fee_%d_%d", blockHeight+1, i))
+				}
+			}
+
+			if err != nil {
+				statuses.Array = append(statuses.Array, "failed")
+				logs.Array = append(logs.Array, err.Error())
+				*parsedActions = []types.Action{}
+			}
+
 			for i, action := range *parsedActions {
 				var err error
 				var result any
 				if action.Kind == "deploy" || action.Kind == "redeploy" {
-					result, err = c.ProcessDeploy(txUnpacked, action)
+					result, err = c.ProcessDeploy(txUnpacked, action, signers)
 					// WORKAROUND: file is too large for column 'actions'
 					(*parsedActions)[i].Args = []string{}
 					txUnpacked.Actions = utils.BorshSerializeAndEncodeHex(parsedActions)
 				} else if action.Kind == "call" {
-					result, err = c.ProcessCall(txUnpacked, action)
+					result, err = c.ProcessCall(txUnpacked, action, signers)
+				} else if action.Kind == "transfer" {
+					result, err = c.ProcessTransfer(txUnpacked, action)
+				} else if action.Kind == "transfer_ownership" {
+					result, err = c.ProcessTransferOwnership(txUnpacked, action, signers)
 				}
 
 				if err != nil {
@@ -363,7 +433,7 @@ func (c *Chain) ProduceBlock() error {
 			statusesStr, _ := json.Marshal(statuses)
 			logsStr, _ := json.Marshal(logs)
 
-			_, err := c.Store.Instance.Exec(
+			_, err = c.Store.Instance.Exec(
 				`INSERT INTO transaction_logs (id, statuses, logs)
 				VALUES (?, ?, ?);`,
 				pSignedTx.ID, statusesStr, logsStr,
@@ -389,6 +459,15 @@ func (c *Chain) ProduceBlock() error {
 			})
 		}
 
+		// run smart index block hooks after the transactions
+		c.ProcessBlockHooks(blockHeight+1, bitcoinHeight)
+
+		// the working branch is dropped, the block is produced again on the next call
+		if err := c.CheckHookTip(bitcoinHeight, bitcoinHash); err != nil {
+			c.doltCheckout("main")
+			return false, err
+		}
+
 		var workingEngineHash string
 		workingEngineHashRaw := c.Store.Instance.QueryRow("SELECT dolt_hashof_db('WORKING')")
 		workingEngineHashRaw.Scan(&workingEngineHash)
@@ -410,16 +489,22 @@ func (c *Chain) ProduceBlock() error {
 
 		prevBlockHeaderHash := utils.SHA256(prevBlockHeaderCompact)
 
-		t, err := merkletree.NewTree(txMerkleTree)
-		if err != nil {
-			panic(err)
+		// blocks produced only for block hooks have no transactions
+		dataHash := []byte{}
+		if len(txMerkleTree) > 0 {
+			t, err := merkletree.NewTree(txMerkleTree)
+			if err != nil {
+				panic(err)
+			}
+			dataHash = t.MerkleRoot()
 		}
 
 		newBlock.Header = types.BlockHeader{
-			ChainID:     "Eastblue",
+			ChainID:     GetChainID(),
+			BitcoinHash: bitcoinHash,
 			Height:      blockHeight + 1,
 			LastBlockID: []byte(prevBlockHeaderHash),
-			DataHash:    t.MerkleRoot(),
+			DataHash:    dataHash,
 			Time:        blockTime,
 			StorageHash: []byte(workingEngineHash),
 		}
@@ -467,11 +552,9 @@ func (c *Chain) ProduceBlock() error {
 
 			return nil
 		})
-
-		c.Unlock()
 	}
 
-	return nil
+	return true, nil
 }
 
 func (c *Chain) ProcessWasmCall(signer string, smartIndexAddress string, functionName string, args []string, kind types.ActionKind) (any, error) {
@@ -484,22 +567,51 @@ func (c *Chain) ProcessWasmCall(signer string, smartIndexAddress string, functio
 		return "", fmt.Errorf("Smart Index not found")
 	}
 
+	manifest, err := c.WasmRuntime.GetManifest(resultWasmBlob, smartIndexAddress)
+	if err != nil {
+		return "", err
+	}
+
+	if !manifest.Allows(functionName, kind) {
+		return "", fmt.Errorf("Function %s is not callable", functionName)
+	}
+
 	return c.WasmRuntime.RunWasmFunction(runtime.Address(signer), resultWasmBlob, smartIndexAddress, functionName, args, kind)
 }
 
-func (c *Chain) ProcessCall(tx types.Transaction, action types.Action) (any, error) {
+func (c *Chain) ProcessCall(tx types.Transaction, action types.Action, signers []string) (any, error) {
+	wasmBlob := c.GetSmartIndexWasm(tx.Receiver)
+	if len(wasmBlob) == 0 {
+		return "", fmt.Errorf("Smart Index not found")
+	}
+
+	manifest, err := c.WasmRuntime.GetManifest(wasmBlob, tx.Receiver)
+	if err != nil {
+		return "", err
+	}
+
+	if manifest.IsOwnerOnly(action.FunctionName) {
+		if err := c.CheckOwnerApproval(tx.Receiver, signers); err != nil {
+			return "", err
+		}
+	}
+
 	return c.ProcessWasmCall(tx.Signer, tx.Receiver, action.FunctionName, action.Args, types.Call)
 }
 
-func (c *Chain) ProcessDeploy(tx types.Transaction, action types.Action) (string, error) {
+func (c *Chain) ProcessDeploy(tx types.Transaction, action types.Action, signers []string) (string, error) {
 	actionSerialized, err := borsh.Serialize(action)
 	if err != nil {
 		return "", err
 	}
 
-	// TODO: Validate wasm file
 	wasmBytes := action.Args[0]
 
+	wasmBlob, err := hex.DecodeString(wasmBytes)
+	if err != nil {
+		return "", err
+	}
+
 	var smartIndexAddress string
 
 	if len(action.Args) == 1 { // new smart index
@@ -517,7 +629,21 @@ func (c *Chain) ProcessDeploy(tx types.Transaction, action types.Action) (string
 
 		// maximum length is 64, trimmed this to 32 chars
 		smartIndexAddress = smartIndexAddress[:32]
+	} else { // redeploy
+		smartIndexAddress = action.Args[1]
+
+		if err := c.CheckOwnerApproval(smartIndexAddress, signers); err != nil {
+			return "", err
+		}
+	}
 
+	// invalid modules are rejected before anything is stored
+	manifest, err := c.WasmRuntime.GetManifest(wasmBlob, smartIndexAddress)
+	if err != nil {
+		return "", fmt.Errorf("invalid wasm: %w", err)
+	}
+
+	if len(action.Args) == 1 {
 		_, err = c.Store.Instance.Exec(
 			`INSERT INTO smart_index (smart_index_address, owner_address, wasm_blob)
 				VALUES (?, ?, UNHEX(?));`, smartIndexAddress, tx.Signer, wasmBytes,
@@ -525,8 +651,12 @@ func (c *Chain) ProcessDeploy(tx types.Transaction, action types.Action) (string
 		if err != nil {
 			return "", err
 		}
-	} else { // redeploy
-		smartIndexAddress = action.Args[1]
+
+		err = c.SetSmartIndexOwners(smartIndexAddress, SmartIndexOwners{Owners: []string{tx.Signer}, Threshold: 1})
+		if err != nil {
+			return "", err
+		}
+	} else {
 		_, err = c.Store.Instance.Exec(
 			`UPDATE smart_index SET wasm_blob = UNHEX(?) WHERE smart_index_address = ?;`, wasmBytes, smartIndexAddress,
 		)
@@ -535,6 +665,10 @@ func (c *Chain) ProcessDeploy(tx types.Transaction, action types.Action) (string
 		}
 	}
 
+	if err := c.registerBlockHook(smartIndexAddress, manifest); err != nil {
+		return "", err
+	}
+
 	return smartIndexAddress, nil
 }
 
@@ -0,0 +1,36 @@
+package chain
+
+import (
+	"eastnode/runtime"
+	store "eastnode/utils/store"
+	"log"
+	"os"
+)
+
+// NewFakeChain returns a chain on the db_test DBs of the working directory, its genesis block credits genesisAccounts
+func NewFakeChain(genesisAccounts []GenesisAccount) *Chain {
+	ClearFakeChain()
+	if err := os.Mkdir("db_test", os.ModeDir|0755); err != nil {
+		log.Panicln(err)
+	}
+
+	bc := new(Chain)
+	bc.Store = store.GetFakeInstance(store.ChainDB, "../utils/store/test/doltdump.sql")
+	bc.WasmRuntime = &runtime.WasmRuntime{Store: *store.GetFakeInstance(store.SmartIndexDB, "../utils/store/test/doltdump.sql")}
+	bc.Mempool = new(Mempool)
+	bc.GenesisAccounts = genesisAccounts
+
+	if err := bc.Mempool.Init(bc.Store.KV); err != nil {
+		log.Panicln(err)
+	}
+
+	bc.ProduceBlock()
+
+	return bc
+}
+
+func ClearFakeChain() {
+	if err := os.RemoveAll("db_test"); err != nil {
+		log.Panicln(err)
+	}
+}
@@ -1,12 +1,10 @@
 package chain
 
 import (
-	"eastnode/runtime"
+	"bytes"
 	"eastnode/types"
-	utils "eastnode/utils/store"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"os"
 	"strings"
 	"testing"
@@ -31,35 +29,16 @@ func initKey() (*secp256k1.PrivateKey, *secp256k1.PublicKey) {
 	return privateKey, publicKey
 }
 
+// the genesis block credits the test key
 func initChainTest() *Chain {
-	clearChainTest()
-	if err := os.Mkdir("db_test", os.ModeDir|0755); err != nil {
-		log.Panicln(err)
-	}
-
-	bc := new(Chain)
-	bc.Store = utils.GetFakeInstance(utils.ChainDB, "../utils/store/test/doltdump.sql")
-	bc.WasmRuntime = &runtime.WasmRuntime{Store: *utils.GetFakeInstance(utils.SmartIndexDB, "../utils/store/test/doltdump.sql")}
-	bc.Mempool = new(Mempool)
-
-	if err := bc.Mempool.Init(bc.Store.KV); err != nil {
-		log.Panicln(err)
-	}
-
-	bc.ProduceBlock()
-
-	return bc
-}
+	_, publicKey := initKey()
 
-func clearChainTest() {
-	if err := os.RemoveAll("db_test"); err != nil {
-		log.Panicln(err)
-	}
+	return NewFakeChain([]GenesisAccount{{Account: hex.EncodeToString(publicKey.SerializeCompressed()), Value: "1000"}})
 }
 
 func TestProcessDeploy(t *testing.T) {
 	bc := initChainTest()
-	defer t.Cleanup(clearChainTest)
+	defer t.Cleanup(ClearFakeChain)
 	// create sample tx
 
 	_, publicKey := initKey()
@@ -83,7 +62,7 @@ func TestProcessDeploy(t *testing.T) {
 		Actions: serializedActionsHex,
 	}
 
-	smartIndexAddress, err := bc.ProcessDeploy(transaction, actions[0])
+	smartIndexAddress, err := bc.ProcessDeploy(transaction, actions[0], []string{transaction.Signer})
 
 	var resultSmartIndexAddress string
 	var resultOwnerAddress string
@@ -104,7 +83,7 @@ func TestProcessDeploy(t *testing.T) {
 
 func TestProcessCall(t *testing.T) {
 	bc := initChainTest()
-	defer t.Cleanup(clearChainTest)
+	defer t.Cleanup(ClearFakeChain)
 
 	TestProcessDeploy(t)
 
@@ -130,7 +109,12 @@ func TestProcessCall(t *testing.T) {
 		Actions:  serializedActionsHex,
 	}
 
-	_, err = bc.ProcessCall(transaction, actions[0])
+	// release.wasm has no manifest, so only its owners can call it
+	if _, err := bc.ProcessCall(transaction, actions[0], []string{"not_owner"}); err == nil {
+		t.Error("Call without the owners approval should fail")
+	}
+
+	_, err = bc.ProcessCall(transaction, actions[0], []string{transaction.Signer})
 
 	if err != nil {
 		t.Error(err)
@@ -155,13 +139,14 @@ func TestProcessCall(t *testing.T) {
 
 func TestProcessRedeploy(t *testing.T) {
 	bc := initChainTest()
-	defer t.Cleanup(clearChainTest)
+	defer t.Cleanup(ClearFakeChain)
 
 	TestProcessDeploy(t)
 
 	// create sample tx
 	_, publicKey := initKey()
 
+	wasmBytes, _ := os.ReadFile("../build/release.wasm")
 	actions := []types.Action{{
 		Kind:         "deploy",
 		FunctionName: "",
@@ -179,13 +164,162 @@ func TestProcessRedeploy(t *testing.T) {
 		Actions: serializedActionsHex,
 	}
 
-	bc.ProcessDeploy(transaction, actions[0])
+	// invalid modules aren't stored
+	if _, err := bc.ProcessDeploy(transaction, actions[0], []string{transaction.Signer}); err == nil {
+		t.Error("Redeploy of an invalid module should fail")
+	}
 
-	var resultWasmBlob string
+	var resultWasmBlob []byte
 	sr := bc.Store.Instance.QueryRow("SELECT wasm_blob FROM smart_index WHERE smart_index_address = ?;", SmartIndexAddress)
 	sr.Scan(&resultWasmBlob)
 
-	if resultWasmBlob != "AAAA" {
-		t.Errorf("Contract not updated uhuy %s", resultWasmBlob)
+	if !bytes.Equal(resultWasmBlob, wasmBytes) {
+		t.Error("Invalid module should not replace the contract")
+	}
+
+	actions[0].Args[0] = hex.EncodeToString(wasmBytes)
+	if _, err := bc.ProcessDeploy(transaction, actions[0], []string{transaction.Signer}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCheckTxInclusion(t *testing.T) {
+	os.Setenv("NETWORK", "regtest")
+	bc := new(Chain)
+
+	transaction := types.Transaction{ChainID: GetChainID(), ExpiryHeight: 10}
+	if err := bc.CheckTxInclusion(transaction, 10); err != nil {
+		t.Errorf("Transaction should be valid at its expiry height: %s", err)
+	}
+
+	if err := bc.CheckTxInclusion(transaction, 11); err == nil {
+		t.Error("Transaction should be expired after its expiry height")
+	}
+
+	transaction.ExpiryHeight = 0
+	if err := bc.CheckTxInclusion(transaction, 1000); err != nil {
+		t.Errorf("Transaction without expiry should be valid: %s", err)
+	}
+
+	transaction.ChainID = "Eastblue-mainnet"
+	if err := bc.CheckTxInclusion(transaction, 1); err == nil {
+		t.Error("Transaction for another chain should be rejected")
+	}
+}
+
+func TestProcessRedeployNotOwner(t *testing.T) {
+	bc := initChainTest()
+	defer t.Cleanup(ClearFakeChain)
+
+	TestProcessDeploy(t)
+
+	wasmBytes, _ := os.ReadFile("../build/release.wasm")
+	actions := []types.Action{{
+		Kind:         "deploy",
+		FunctionName: "",
+		Args:         []string{hex.EncodeToString(wasmBytes), SmartIndexAddress},
+	}}
+
+	transaction := types.Transaction{
+		Signer: "not_the_owner",
+	}
+
+	_, err := bc.ProcessDeploy(transaction, actions[0], []string{transaction.Signer})
+	if err == nil {
+		t.Error("Redeploy by a non owner should fail")
+	}
+
+	// 2-of-2 owners need both signatures
+	_, publicKey := initKey()
+	transferAction := types.Action{
+		Kind: "transfer_ownership",
+		Args: []string{"2", publicKey.X().String(), "second_owner"},
+	}
+	transferTransaction := types.Transaction{Signer: publicKey.X().String(), Receiver: SmartIndexAddress}
+
+	if _, err := bc.ProcessTransferOwnership(transferTransaction, transferAction, []string{publicKey.X().String()}); err != nil {
+		t.Error(err)
+	}
+
+	transaction.Signer = publicKey.X().String()
+	if _, err := bc.ProcessDeploy(transaction, actions[0], []string{publicKey.X().String()}); err == nil {
+		t.Error("Redeploy with 1 of 2 owner signatures should fail")
+	}
+
+	if _, err := bc.ProcessDeploy(transaction, actions[0], []string{publicKey.X().String(), "second_owner"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessTransfer(t *testing.T) {
+	bc := initChainTest()
+	defer t.Cleanup(ClearFakeChain)
+
+	_, publicKey := initKey()
+	signer := hex.EncodeToString(publicKey.SerializeCompressed())
+
+	receiverKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := hex.EncodeToString(receiverKey.PubKey().SerializeCompressed())
+
+	// genesis allocation
+	balance, err := bc.GetBalance(signer)
+	if err != nil {
+		t.Error(err)
+	}
+	if balance != 1000 {
+		t.Errorf("Genesis balance incorrect %d", balance)
+	}
+
+	transaction := types.Transaction{
+		Signer:   signer,
+		Receiver: receiver,
+	}
+
+	if _, err := bc.ProcessTransfer(transaction, types.Action{Kind: "transfer", Args: []string{"400"}}); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := bc.ProcessTransfer(transaction, types.Action{Kind: "transfer", Args: []string{"601"}}); err == nil {
+		t.Error("Transfer over balance should fail")
+	}
+
+	balance, _ = bc.GetBalance(receiver)
+	if balance != 400 {
+		t.Errorf("Receiver balance incorrect %d", balance)
+	}
+
+	transaction.Receiver = "bc1ph02hv4dc9afhcycs04vtawkmmm055j3g39w7mqur6d2x5ng4dgmshavfvj"
+	if _, err := bc.ProcessTransfer(transaction, types.Action{Kind: "transfer", Args: []string{"1"}}); err == nil {
+		t.Error("Transfer to an address should fail")
+	}
+}
+
+func TestLoadGenesisAccounts(t *testing.T) {
+	accounts, err := LoadGenesisAccounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != len(defaultGenesisAccounts) {
+		t.Errorf("Expected the default allocations, got %+v", accounts)
+	}
+
+	path := t.TempDir() + "/genesis.json"
+	t.Setenv("GENESIS_FILE", path)
+	os.WriteFile(path, []byte(`[{"account": "account", "value": "1000"}]`), 0600)
+
+	accounts, err = LoadGenesisAccounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].Value != "1000" {
+		t.Errorf("Genesis file allocations incorrect %+v", accounts)
+	}
+
+	os.WriteFile(path, []byte(`[{"account": "a", "value": "-1"}]`), 0600)
+	if _, err := LoadGenesisAccounts(); err == nil {
+		t.Error("Invalid genesis value should fail")
 	}
 }
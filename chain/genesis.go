@@ -0,0 +1,65 @@
+package chain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// Account is credited Value by the genesis block, balances are spent by signing with
+// the hex encoded public key of the account
+type GenesisAccount struct {
+	Account string `json:"account"`
+	Value   string `json:"value"`
+}
+
+// the allocations of chains started without a GENESIS_FILE
+var defaultGenesisAccounts = []GenesisAccount{
+	{
+		Account: "bc1ph02hv4dc9afhcycs04vtawkmmm055j3g39w7mqur6d2x5ng4dgmshavfvj",
+		Value:   "1000",
+	},
+	{
+		Account: "bc1pkskdm7qk0z4gr8cgy38ysa00gyftj364gmf3uruse80c6gzunf6s0ywcsh",
+		Value:   "5000",
+	},
+	{
+		Account: "bc1pkskdm7qk0z4gr8cgy38ysa00gyftj364gmf3uruse80c6gzunf6s0ywcsh",
+		Value:   "10000",
+	},
+}
+
+// LoadGenesisAccounts reads the genesis allocations from the JSON file at GENESIS_FILE,
+// e.g. [{"account": "<public key>", "value": "1000"}], the default allocations are used without one
+func LoadGenesisAccounts() ([]GenesisAccount, error) {
+	path := os.Getenv("GENESIS_FILE")
+	if path == "" {
+		return defaultGenesisAccounts, nil
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis file: %w", err)
+	}
+
+	accounts := []GenesisAccount{}
+	if err := json.Unmarshal(raw, &accounts); err != nil {
+		return nil, fmt.Errorf("invalid genesis file: %w", err)
+	}
+	if len(accounts) == 0 {
+		return nil, errors.New("genesis file has no accounts")
+	}
+
+	for _, account := range accounts {
+		if account.Account == "" {
+			return nil, errors.New("genesis account can't be empty")
+		}
+		if _, err := strconv.ParseUint(account.Value, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid genesis value %s for %s", account.Value, account.Account)
+		}
+	}
+
+	return accounts, nil
+}
@@ -0,0 +1,177 @@
+package chain
+
+import (
+	"eastnode/runtime"
+	"eastnode/types"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+// maximum bitcoin blocks processed per smart index in a single chain block
+const MAX_HOOK_BLOCKS = 10
+
+// consecutive failures after which a block hook is disabled until the smart index is redeployed
+const MAX_HOOK_FAILURES = 5
+
+type BlockHook struct {
+	SmartIndexAddress string
+	FunctionName      string
+	LastHeight        int64
+	Failures          int
+}
+
+// registerBlockHook stores the block hook declared in manifest,
+// a redeploy keeps the progress of the previous hook and enables it again
+func (c *Chain) registerBlockHook(smartIndexAddress string, manifest *runtime.Manifest) error {
+	if manifest == nil || manifest.BlockHook == "" {
+		_, err := c.Store.Instance.Exec("DELETE FROM smart_index_hooks WHERE smart_index_address = ?;", smartIndexAddress)
+		return err
+	}
+
+	_, err := c.Store.Instance.Exec(
+		`INSERT INTO smart_index_hooks (smart_index_address, function_name, last_height)
+			VALUES (?, ?, ?)
+			ON DUPLICATE KEY UPDATE function_name = ?, failures = 0;`,
+		smartIndexAddress, manifest.BlockHook, manifest.BlockHookStartHeight-1, manifest.BlockHook,
+	)
+
+	return err
+}
+
+func (c *Chain) GetBlockHooks() ([]BlockHook, error) {
+	rows, err := c.Store.Instance.Query(
+		"SELECT smart_index_address, function_name, last_height, failures FROM smart_index_hooks ORDER BY smart_index_address",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hooks := []BlockHook{}
+	for rows.Next() {
+		hook := BlockHook{}
+		if err := rows.Scan(&hook.SmartIndexAddress, &hook.FunctionName, &hook.LastHeight, &hook.Failures); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+
+	return hooks, rows.Err()
+}
+
+// HasPendingBlockHooks returns true if an enabled block hook is behind bitcoinHeight, the tip returned by HookTip
+func (c *Chain) HasPendingBlockHooks(bitcoinHeight int64) bool {
+	if bitcoinHeight < 0 {
+		return false
+	}
+
+	var pending int
+	c.Store.Instance.QueryRow("SELECT COUNT(*) FROM smart_index_hooks WHERE last_height < ? AND failures < ?", bitcoinHeight, MAX_HOOK_FAILURES).Scan(&pending)
+
+	return pending > 0
+}
+
+// HookTip returns the last indexed bitcoin block, the block hooks of a chain block run up to it.
+// Its hash is recorded in the block header, -1 if there is no indexer or no indexed block
+func (c *Chain) HookTip() (int64, string, error) {
+	if c.WasmRuntime.IndexerDbRepo == nil {
+		return -1, "", nil
+	}
+
+	lastHeight, err := c.WasmRuntime.IndexerDbRepo.GetLastHeight()
+	if err != nil || lastHeight < 0 {
+		return -1, "", err
+	}
+
+	hash, err := c.WasmRuntime.IndexerDbRepo.GetBlockHashByHeight(uint64(lastHeight))
+	if err != nil {
+		return -1, "", err
+	}
+	if hash == nil {
+		return -1, "", fmt.Errorf("bitcoin block %d not indexed", lastHeight)
+	}
+
+	return int64(lastHeight), *hash, nil
+}
+
+// CheckHookTip returns an error unless the indexer still has bitcoinHash at bitcoinHeight,
+// otherwise a reorg changed the input of the block hooks while they ran
+func (c *Chain) CheckHookTip(bitcoinHeight int64, bitcoinHash string) error {
+	if bitcoinHeight < 0 {
+		return nil
+	}
+
+	hash, err := c.WasmRuntime.IndexerDbRepo.GetBlockHashByHeight(uint64(bitcoinHeight))
+	if err != nil {
+		return err
+	}
+	if hash == nil || *hash != bitcoinHash {
+		return fmt.Errorf("bitcoin block %d %s was reorged while the block hooks ran", bitcoinHeight, bitcoinHash)
+	}
+
+	return nil
+}
+
+// ProcessBlockHooks runs every block hook for the bitcoin blocks up to bitcoinHeight since its checkpoint,
+// must run on the working branch during block production
+func (c *Chain) ProcessBlockHooks(blockHeight uint64, bitcoinHeight int64) {
+	hooks, err := c.GetBlockHooks()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	for _, hook := range hooks {
+		if hook.Failures >= MAX_HOOK_FAILURES {
+			continue
+		}
+
+		wasmBlob := c.GetSmartIndexWasm(hook.SmartIndexAddress)
+
+		for h := hook.LastHeight + 1; h <= bitcoinHeight && h <= hook.LastHeight+MAX_HOOK_BLOCKS; h++ {
+			height := strconv.FormatInt(h, 10)
+
+			_, err := c.WasmRuntime.RunWasmFunction(runtime.Address(""), wasmBlob, hook.SmartIndexAddress, hook.FunctionName, []string{height, height}, types.Call)
+			if err != nil {
+				// retried on the next block producer tick, up to MAX_HOOK_FAILURES times
+				log.Printf("block hook %s failed at height %d: %s", hook.SmartIndexAddress, h, err)
+				c.doltHardReset("working_branch")
+
+				_, err = c.Store.Instance.Exec(
+					"UPDATE smart_index_hooks SET failures = failures + 1 WHERE smart_index_address = ?;", hook.SmartIndexAddress,
+				)
+				if err != nil {
+					panic(err)
+				}
+				if hook.Failures+1 >= MAX_HOOK_FAILURES {
+					log.Printf("block hook %s disabled after %d failures", hook.SmartIndexAddress, MAX_HOOK_FAILURES)
+				}
+
+				c.doltAddAndCommit(fmt.Sprintf("hook_failed_%d_%s_%d", blockHeight, hook.SmartIndexAddress, h))
+				break
+			}
+
+			_, err = c.Store.Instance.Exec(
+				"UPDATE smart_index_hooks SET last_height = ?, failures = 0 WHERE smart_index_address = ?;", h, hook.SmartIndexAddress,
+			)
+			if err != nil {
+				panic(err)
+			}
+
+			c.doltAddAndCommit(fmt.Sprintf("hook_%d_%s_%d", blockHeight, hook.SmartIndexAddress, h))
+		}
+	}
+}
+
+// StartBlockProducer produces a block every interval, so block hooks run without new transactions
+func (c *Chain) StartBlockProducer(interval time.Duration) {
+	for {
+		time.Sleep(interval)
+
+		if err := c.ProduceBlock(); err != nil {
+			log.Println(err)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package chain
+
+import (
+	"database/sql"
+	"eastnode/types"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+)
+
+// Owners of a smart index, redeploys and transfers need Threshold distinct owner signatures
+type SmartIndexOwners struct {
+	Owners    []string `json:"owners"`
+	Threshold int      `json:"threshold"`
+}
+
+func (c *Chain) GetSmartIndexOwners(smartIndexAddress string) (*SmartIndexOwners, error) {
+	var ownersRaw string
+	var threshold int
+
+	err := c.Store.Instance.QueryRow(
+		"SELECT owners, threshold FROM smart_index_owners WHERE smart_index_address = ?", smartIndexAddress,
+	).Scan(&ownersRaw, &threshold)
+
+	if err == sql.ErrNoRows {
+		// smart indexes deployed before owner sets only have a single owner
+		var ownerAddress string
+		err = c.Store.Instance.QueryRow(
+			"SELECT owner_address FROM smart_index WHERE smart_index_address = ?", smartIndexAddress,
+		).Scan(&ownerAddress)
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("Smart Index not found")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &SmartIndexOwners{Owners: []string{ownerAddress}, Threshold: 1}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	owners := new(SmartIndexOwners)
+	owners.Threshold = threshold
+	if err := json.Unmarshal([]byte(ownersRaw), &owners.Owners); err != nil {
+		return nil, err
+	}
+
+	return owners, nil
+}
+
+func (c *Chain) SetSmartIndexOwners(smartIndexAddress string, owners SmartIndexOwners) error {
+	if len(owners.Owners) == 0 {
+		return errors.New("owners can't be empty")
+	}
+
+	if owners.Threshold < 1 || owners.Threshold > len(owners.Owners) {
+		return fmt.Errorf("invalid threshold %d for %d owners", owners.Threshold, len(owners.Owners))
+	}
+
+	for i, owner := range owners.Owners {
+		if slices.Contains(owners.Owners[:i], owner) {
+			return fmt.Errorf("duplicate owner %s", owner)
+		}
+	}
+
+	ownersRaw, err := json.Marshal(owners.Owners)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.Store.Instance.Exec(
+		`REPLACE INTO smart_index_owners (smart_index_address, owners, threshold)
+			VALUES (?, ?, ?);`, smartIndexAddress, ownersRaw, owners.Threshold,
+	)
+	if err != nil {
+		return err
+	}
+
+	// owner_address keeps pointing to the first owner for existing readers
+	_, err = c.Store.Instance.Exec(
+		`UPDATE smart_index SET owner_address = ? WHERE smart_index_address = ?;`, owners.Owners[0], smartIndexAddress,
+	)
+
+	return err
+}
+
+// CheckOwnerApproval returns an error unless enough distinct owners are among signers
+func (c *Chain) CheckOwnerApproval(smartIndexAddress string, signers []string) error {
+	owners, err := c.GetSmartIndexOwners(smartIndexAddress)
+	if err != nil {
+		return err
+	}
+
+	approvals := 0
+	for _, owner := range owners.Owners {
+		if slices.Contains(signers, owner) {
+			approvals++
+		}
+	}
+
+	if approvals < owners.Threshold {
+		return fmt.Errorf("not enough owner signatures: %d of %d", approvals, owners.Threshold)
+	}
+
+	return nil
+}
+
+// ProcessTransferOwnership replaces the owners of tx.Receiver
+// Args: [threshold, owner...]
+func (c *Chain) ProcessTransferOwnership(tx types.Transaction, action types.Action, signers []string) (string, error) {
+	if len(action.Args) < 2 {
+		return "", errors.New("transfer_ownership requires a threshold and at least one owner")
+	}
+
+	threshold, err := strconv.Atoi(action.Args[0])
+	if err != nil {
+		return "", fmt.Errorf("invalid threshold: %w", err)
+	}
+
+	if err := c.CheckOwnerApproval(tx.Receiver, signers); err != nil {
+		return "", err
+	}
+
+	err = c.SetSmartIndexOwners(tx.Receiver, SmartIndexOwners{
+		Owners:    action.Args[1:],
+		Threshold: threshold,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return tx.Receiver, nil
+}
@@ -5,15 +5,51 @@ import (
 	"eastnode/indexer/repository/bitcoin"
 	"eastnode/indexer/repository/db"
 	storeDB "eastnode/utils/store"
+	"fmt"
+	"log"
 	"os"
 )
 
 func main() {
-	bitcoinRepo := bitcoin.NewBitcoinRepo(os.Getenv("BTC_RPC_URL"), "east", "east")
+	bitcoinRepo := bitcoin.NewBitcoinRepoFromEnv()
 	s := storeDB.GetInstance(storeDB.IndexerDB)
 	dbRepo := db.NewDBRepository(s.Gorm)
-	indexerRepo := indexer.NewIndexer(dbRepo, bitcoinRepo)
+
+	// `indexer squash-history` drops the Dolt history of the indexer DB, e.g. the rows deleted by pruning,
+	// run it with the indexer and the JSON-RPC server stopped
+	if len(os.Args) > 1 && os.Args[1] == "squash-history" {
+		lastHeight, err := dbRepo.GetLastHeight()
+		if err != nil {
+			log.Panicln(err)
+		}
+		if err := dbRepo.SquashHistory(fmt.Sprintf("Squash history at block %d", lastHeight)); err != nil {
+			log.Panicln(err)
+		}
+
+		log.Printf("Squashed the indexer history at block %d", lastHeight)
+		return
+	}
+
+	if os.Getenv("BTC_BLOCKS_DIR") != "" {
+		err := indexer.SyncFromBlockFiles(dbRepo, bitcoinRepo, os.Getenv("BTC_BLOCKS_DIR"))
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// blocks can be read from an esplora API instead of bitcoind
+	var blockSource bitcoin.BitcoinRepositoryInterface = bitcoinRepo
+	if os.Getenv("BTC_ESPLORA_URL") != "" {
+		blockSource = bitcoin.NewEsploraRepo(os.Getenv("BTC_ESPLORA_URL"))
+	}
+
+	indexerRepo := indexer.NewIndexer(dbRepo, blockSource)
 	scheduler := indexer.NewScheduler(indexerRepo)
 
+	// unconfirmed transactions are always read from bitcoind
+	if os.Getenv("INDEXER_MEMPOOL") == "true" {
+		go indexer.NewMempool(indexerRepo, bitcoinRepo).Start()
+	}
+
 	scheduler.Start()
 }
@@ -2,13 +2,14 @@ package main
 
 import (
 	"eastnode/chain"
+	"eastnode/indexer"
 	"eastnode/indexer/repository/bitcoin"
 	indexerDb "eastnode/indexer/repository/db"
 	"eastnode/jsonrpc"
 	store "eastnode/utils/store"
 	"log"
 	"net/http"
-	"os"
+	"time"
 
 	_ "github.com/dolthub/driver"
 	"github.com/gorilla/mux"
@@ -30,23 +31,33 @@ func main() {
 	blockchain := new(chain.Chain)
 	bc := blockchain.Init(indexerDbRepo)
 
+	// produce blocks for smart index block hooks
+	go bc.StartBlockProducer(1 * time.Second)
+
 	rpcServer := rpc.NewServer()
 
 	rpcServer.RegisterCodec(json.NewCodec(), "application/json")
 	rpcServer.RegisterCodec(json.NewCodec(), "application/json;charset=UTF-8")
 
+	// the indexer runs in another process, its status is read from the DB and the bitcoin node
+	bitcoinRepo := bitcoin.NewBitcoinRepoFromEnv()
+	indexerStatus := indexer.WatchStatus(indexerDbRepo, bitcoinRepo, indexer.STATUS_POLL_INTERVAL)
+
 	runtimeServer := &jsonrpc.RuntimeServer{
-		Chain: bc,
+		Chain:         bc,
+		IndexerStatus: indexerStatus,
 	}
 	commonServer := &jsonrpc.CommonServer{
-		Chain: bc,
+		Chain:         bc,
+		IndexerStatus: indexerStatus,
 	}
 	btcServer := &jsonrpc.BitcoinServer{
-		BitcoinRepo: bitcoin.NewBitcoinRepo(os.Getenv("BTC_RPC_URL"), "east", "east"),
+		BitcoinRepo: bitcoinRepo,
 	}
 
 	rpcServer.RegisterService(runtimeServer, "Runtime")
 	rpcServer.RegisterService(commonServer, "Common")
+	rpcServer.RegisterService(&jsonrpc.IndexerServer{DbRepo: indexerDbRepo, IndexerStatus: indexerStatus}, "Indexer")
 
 	router := mux.NewRouter()
 	router.Handle("/", rpcServer)
@@ -6,6 +6,7 @@ import (
 	indexerDb "eastnode/indexer/repository/db"
 	storeDB "eastnode/utils/store"
 	"os"
+	"time"
 
 	"eastnode/chain"
 	"eastnode/jsonrpc"
@@ -27,12 +28,30 @@ func main() {
 	}
 	log.Println("Initializing...")
 
-	bitcoinRepo := bitcoin.NewBitcoinRepo(os.Getenv("BTC_RPC_URL"), "east", "east")
+	bitcoinRepo := bitcoin.NewBitcoinRepoFromEnv()
 	s := storeDB.GetInstance(storeDB.IndexerDB)
 	indexerDbRepo := indexerDb.NewDBRepository(s.Gorm)
-	indexerRepo := indexer.NewIndexer(indexerDbRepo, bitcoinRepo)
+	if os.Getenv("BTC_BLOCKS_DIR") != "" {
+		err := indexer.SyncFromBlockFiles(indexerDbRepo, bitcoinRepo, os.Getenv("BTC_BLOCKS_DIR"))
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	// blocks can be read from an esplora API instead of bitcoind
+	var blockSource bitcoin.BitcoinRepositoryInterface = bitcoinRepo
+	if os.Getenv("BTC_ESPLORA_URL") != "" {
+		blockSource = bitcoin.NewEsploraRepo(os.Getenv("BTC_ESPLORA_URL"))
+	}
+
+	indexerRepo := indexer.NewIndexer(indexerDbRepo, blockSource)
 	scheduler := indexer.NewScheduler(indexerRepo)
 
+	// unconfirmed transactions are always read from bitcoind
+	if os.Getenv("INDEXER_MEMPOOL") == "true" {
+		go indexer.NewMempool(indexerRepo, bitcoinRepo).Start()
+	}
+
 	go func() {
 		scheduler.Start()
 	}()
@@ -41,20 +60,26 @@ func main() {
 	blockchain := new(chain.Chain)
 	bc := blockchain.Init(indexerDbRepo)
 
+	// produce blocks for smart index block hooks
+	go bc.StartBlockProducer(1 * time.Second)
+
 	rpcServer := rpc.NewServer()
 
 	rpcServer.RegisterCodec(json.NewCodec(), "application/json")
 	rpcServer.RegisterCodec(json.NewCodec(), "application/json;charset=UTF-8")
 
 	runtimeServer := &jsonrpc.RuntimeServer{
-		Chain: bc,
+		Chain:         bc,
+		IndexerStatus: indexerRepo.Status,
 	}
 	commonServer := &jsonrpc.CommonServer{
-		Chain: bc,
+		Chain:         bc,
+		IndexerStatus: indexerRepo.Status,
 	}
 
 	rpcServer.RegisterService(runtimeServer, "Runtime")
 	rpcServer.RegisterService(commonServer, "Common")
+	rpcServer.RegisterService(&jsonrpc.IndexerServer{DbRepo: indexerDbRepo, Indexer: indexerRepo}, "Indexer")
 
 	router := mux.NewRouter()
 	router.Handle("/", rpcServer)
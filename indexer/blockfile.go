@@ -0,0 +1,48 @@
+package indexer
+
+import (
+	"eastnode/indexer/repository/bitcoin"
+	"eastnode/indexer/repository/db"
+	"log"
+)
+
+// SyncFromBlockFiles indexes the blocks in the bitcoind blocks directory up to REORG_DEPTH_CHECK blocks
+// below the node tip, the remaining blocks are left to the RPC scheduler
+func SyncFromBlockFiles(dbRepo *db.DBRepository, bitcoinRepo *bitcoin.BitcoinRepository, blocksDir string) error {
+	tipHash, err := bitcoinRepo.GetBestBlockHash()
+	if err != nil {
+		return err
+	}
+
+	fileRepo, err := bitcoin.NewBlockFileRepo(blocksDir, tipHash)
+	if err != nil {
+		return err
+	}
+
+	// the network, retention and status of the RPC indexer, reading local files needs no rate limit
+	fileIndexer := NewIndexer(dbRepo, fileRepo)
+	fileIndexer.fetcher = NewBlockFetcher(fileRepo, DEFAULT_FETCH_WORKERS, 0)
+
+	indexerLastHeight, err := dbRepo.GetLastHeight()
+	if err != nil {
+		return err
+	}
+	// nothing indexed yet, start from the configured height
+	if indexerLastHeight < 0 {
+		indexerLastHeight = fileIndexer.retention.StartHeight - 1
+	}
+
+	fileLastHeight, err := fileRepo.GetBlockCount()
+	if err != nil {
+		return err
+	}
+
+	toHeight := fileLastHeight - REORG_DEPTH_CHECK
+	if toHeight <= indexerLastHeight {
+		return nil
+	}
+
+	log.Printf("Syncing blocks %d to %d from %s", indexerLastHeight+1, toHeight, blocksDir)
+
+	return fileIndexer.SyncBlocks(indexerLastHeight+1, toHeight)
+}
@@ -0,0 +1,212 @@
+package indexer
+
+import (
+	"eastnode/indexer/repository/bitcoin"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+const DEFAULT_FETCH_WORKERS = 8
+const MAX_FETCH_ATTEMPTS = 5
+const MAX_FETCH_DELAY = 10 * time.Second
+
+// milliseconds between requests, overridden by INDEXER_SLEEP_TIME
+const DEFAULT_FETCH_DELAY = 10
+
+// heights per getblockhash batch, for repositories supporting batch requests
+const BLOCK_HASH_BATCH_SIZE = 100
+
+type blockHashBatcher interface {
+	GetBlockHashes(heights []int32) ([]string, error)
+}
+
+// repositories making several requests per block, e.g. Esplora pages, take every request from the limiter
+type requestLimited interface {
+	SetRequestLimiter(wait func())
+}
+
+type FetchedBlock struct {
+	Height int32
+	Block  *bitcoin.GetBlock
+	Err    error
+}
+
+// BlockFetcher fetches blocks for many heights concurrently and returns them in height order
+type BlockFetcher struct {
+	bitcoinRepo bitcoin.BitcoinRepositoryInterface
+	workers     int
+	limiter     *rateLimiter
+	// the repository waits for the limiter itself
+	limitsRequests bool
+}
+
+func NewBlockFetcher(bitcoinRepo bitcoin.BitcoinRepositoryInterface, workers int, delay time.Duration) *BlockFetcher {
+	if workers < 1 {
+		workers = 1
+	}
+
+	limiter := &rateLimiter{delay: delay, minDelay: delay}
+	limited, limitsRequests := bitcoinRepo.(requestLimited)
+	if limitsRequests {
+		limited.SetRequestLimiter(limiter.wait)
+	}
+
+	return &BlockFetcher{bitcoinRepo, workers, limiter, limitsRequests}
+}
+
+// Fetch streams the blocks from fromHeight to toHeight in order, at most workers blocks are
+// fetched ahead of the consumer. Fetching stops after the first error or when done is closed.
+func (f *BlockFetcher) Fetch(fromHeight int32, toHeight int32, done <-chan struct{}) <-chan FetchedBlock {
+	out := make(chan FetchedBlock)
+	window := make(chan chan FetchedBlock, f.workers)
+
+	go func() {
+		defer close(window)
+
+		hashes := map[int32]string{}
+
+		for h := fromHeight; h <= toHeight; h++ {
+			if _, ok := hashes[h]; !ok {
+				hashes = f.fetchHashes(h, min(toHeight, h+BLOCK_HASH_BATCH_SIZE-1))
+			}
+
+			result := make(chan FetchedBlock, 1)
+
+			select {
+			case window <- result:
+			case <-done:
+				return
+			}
+
+			go func(h int32, blockHash string) {
+				result <- f.fetch(h, blockHash)
+			}(h, hashes[h])
+		}
+	}()
+
+	go func() {
+		defer close(out)
+
+		for result := range window {
+			fetched := <-result
+
+			select {
+			case out <- fetched:
+			case <-done:
+				return
+			}
+
+			if fetched.Err != nil {
+				return
+			}
+		}
+	}()
+
+	return out
+}
+
+// fetchHashes gets the block hashes of a range in one batch request, missing hashes are
+// fetched one by one by fetch
+func (f *BlockFetcher) fetchHashes(fromHeight int32, toHeight int32) map[int32]string {
+	hashes := map[int32]string{fromHeight: ""}
+
+	batcher, ok := f.bitcoinRepo.(blockHashBatcher)
+	if !ok || fromHeight == toHeight {
+		return hashes
+	}
+
+	heights := []int32{}
+	for h := fromHeight; h <= toHeight; h++ {
+		heights = append(heights, h)
+	}
+
+	f.waitRequest()
+	batch, err := batcher.GetBlockHashes(heights)
+	if err != nil {
+		log.Printf("fetch block hashes %d to %d failed: %s", fromHeight, toHeight, err)
+		return hashes
+	}
+
+	for i, h := range heights {
+		hashes[h] = batch[i]
+	}
+
+	return hashes
+}
+
+func (f *BlockFetcher) fetch(height int32, blockHash string) FetchedBlock {
+	var err error
+
+	for attempt := 1; attempt <= MAX_FETCH_ATTEMPTS; attempt++ {
+		var block *bitcoin.GetBlock
+
+		if blockHash == "" {
+			f.waitRequest()
+			blockHash, err = f.bitcoinRepo.GetBlockHash(height)
+		}
+		if err == nil {
+			f.waitRequest()
+			block, err = f.bitcoinRepo.GetBlock(blockHash)
+		}
+
+		if err == nil {
+			f.limiter.success()
+			return FetchedBlock{Height: height, Block: block}
+		}
+
+		// beyond the tip, retrying won't help
+		if bitcoin.IsBlockNotFound(err) {
+			break
+		}
+
+		log.Printf("fetch block %d failed (attempt %d): %s", height, attempt, err)
+		f.limiter.failure()
+	}
+
+	return FetchedBlock{Height: height, Err: fmt.Errorf("failed to fetch block %d: %w", height, err)}
+}
+
+// waitRequest waits for the limiter unless the repository waits for every request itself
+func (f *BlockFetcher) waitRequest() {
+	if !f.limitsRequests {
+		f.limiter.wait()
+	}
+}
+
+// rateLimiter spaces out the requests of every worker by delay, backing off on failures and speeding up on successes.
+// It's a token bucket of size 1, each wait takes the next free slot
+type rateLimiter struct {
+	mu       sync.Mutex
+	delay    time.Duration
+	minDelay time.Duration
+	next     time.Time
+}
+
+func (l *rateLimiter) wait() {
+	l.mu.Lock()
+	now := time.Now()
+	slot := now
+	if l.next.After(now) {
+		slot = l.next
+	}
+	l.next = slot.Add(l.delay)
+	l.mu.Unlock()
+
+	time.Sleep(slot.Sub(now))
+}
+
+func (l *rateLimiter) success() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.delay = max(l.minDelay, l.delay*9/10)
+}
+
+func (l *rateLimiter) failure() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.delay = min(MAX_FETCH_DELAY, max(100*time.Millisecond, l.delay*2))
+}
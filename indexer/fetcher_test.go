@@ -0,0 +1,96 @@
+package indexer
+
+import (
+	"eastnode/indexer/repository/bitcoin"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBlockFetcher_Fetch(t *testing.T) {
+	mockBitcoinRepo := bitcoin.NewMockBitcoinRepo()
+
+	endHeight := 100
+	for i := 0; i <= endHeight; i++ {
+		mockBitcoinRepo.AddOrReplaceBlock(int32(i))
+	}
+
+	fetcher := NewBlockFetcher(mockBitcoinRepo, 8, 0)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	expectedHeight := int32(0)
+	for fetched := range fetcher.Fetch(0, int32(endHeight), done) {
+		if fetched.Err != nil {
+			t.Fatalf("Failed to fetch block %d: %v", fetched.Height, fetched.Err)
+		}
+
+		if fetched.Height != expectedHeight {
+			t.Fatalf("Blocks out of order. Expected: %d, Got: %d", expectedHeight, fetched.Height)
+		}
+
+		blockHash, _ := mockBitcoinRepo.GetBlockHash(fetched.Height)
+		if fetched.Block.Hash != blockHash {
+			t.Errorf("Block hash mismatch at height %d. Expected: %s, Got: %s", fetched.Height, blockHash, fetched.Block.Hash)
+		}
+
+		expectedHeight++
+	}
+
+	if expectedHeight != int32(endHeight+1) {
+		t.Errorf("Expected %d blocks, got %d", endHeight+1, expectedHeight)
+	}
+}
+
+func TestBlockFetcher_FetchBeyondTip(t *testing.T) {
+	mockBitcoinRepo := bitcoin.NewMockBitcoinRepo()
+	for i := 0; i <= 5; i++ {
+		mockBitcoinRepo.AddOrReplaceBlock(int32(i))
+	}
+
+	fetcher := NewBlockFetcher(mockBitcoinRepo, 1, 0)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	var lastErr error
+	for fetched := range fetcher.Fetch(0, 6, done) {
+		lastErr = fetched.Err
+	}
+
+	if lastErr == nil {
+		t.Error("Expected an error when fetching beyond the tip")
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter := &rateLimiter{delay: 20 * time.Millisecond, minDelay: 20 * time.Millisecond}
+
+	// requests of concurrent workers are spaced out too
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			limiter.wait()
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("Requests not spaced out, 5 requests took %s", elapsed)
+	}
+}
+
+func TestBlockFetcher_RequestLimited(t *testing.T) {
+	esplora := bitcoin.NewEsploraRepo("http://localhost")
+	if fetcher := NewBlockFetcher(esplora, 2, time.Millisecond); !fetcher.limitsRequests {
+		t.Error("Esplora pages should wait for the fetcher limiter")
+	}
+
+	if fetcher := NewBlockFetcher(bitcoin.NewMockBitcoinRepo(), 2, time.Millisecond); fetcher.limitsRequests {
+		t.Error("Mock repository should be limited by the fetcher")
+	}
+}
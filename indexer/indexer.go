@@ -1,14 +1,19 @@
 package indexer
 
 import (
+	"eastnode/indexer/ordinals"
 	"eastnode/indexer/repository/bitcoin"
 	"eastnode/indexer/repository/db"
+	"eastnode/indexer/runes"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
 const MAX_BLOCK_FLUSH = 500
@@ -16,153 +21,270 @@ const MAX_BLOCK_FLUSH = 500
 type Indexer struct {
 	DbRepo      *db.DBRepository
 	bitcoinRepo bitcoin.BitcoinRepositoryInterface
+	fetcher     *BlockFetcher
+
+	// addresses are derived for this network
+	network string
+
+	retention Retention
+
+	// sync progress, read by the RPC server
+	Status *Status
+
+	// vouts not flushed yet, indexed by outpoint for prevout resolution
+	pendingVouts map[string]int
+	indexedVouts int
 }
 
 func NewIndexer(dbRepo *db.DBRepository, bitcoinRepo bitcoin.BitcoinRepositoryInterface) *Indexer {
-	return &Indexer{dbRepo, bitcoinRepo}
+	workers := DEFAULT_FETCH_WORKERS
+	if os.Getenv("INDEXER_WORKERS") != "" {
+		w, err := strconv.Atoi(os.Getenv("INDEXER_WORKERS"))
+		if err != nil {
+			panic(err)
+		}
+		workers = w
+	}
+
+	// minimum delay between RPC requests of all workers, because of RPC rate limit
+	sleepTime := DEFAULT_FETCH_DELAY
+	if os.Getenv("INDEXER_SLEEP_TIME") != "" {
+		s, err := strconv.Atoi(os.Getenv("INDEXER_SLEEP_TIME"))
+		if err != nil {
+			panic(err)
+		}
+		sleepTime = s
+	}
+
+	fetcher := NewBlockFetcher(bitcoinRepo, workers, time.Duration(sleepTime)*time.Millisecond)
+
+	network := os.Getenv("NETWORK")
+	if network == "" {
+		network = bitcoin.DEFAULT_NETWORK
+	}
+
+	retention, err := RetentionFromEnv()
+	if err != nil {
+		panic(err)
+	}
+
+	return &Indexer{DbRepo: dbRepo, bitcoinRepo: bitcoinRepo, fetcher: fetcher, network: network, retention: retention, Status: NewStatus()}
+}
+
+// ReorgError is returned when a fetched block doesn't extend the indexed chain
+type ReorgError struct {
+	Height int32
+}
+
+func (e *ReorgError) Error() string {
+	return fmt.Sprintf("block at height %d doesn't extend the indexed chain", e.Height)
 }
 
 func (i *Indexer) SyncBlocks(startHeight int32, endHeight int32) error {
 	if endHeight-startHeight > MAX_BLOCK_FLUSH {
 		// Many blocks to sync, log the sync process
-		log.Printf("Syncing %d blocks from height %d to %d", endHeight-startHeight, startHeight, endHeight)
+		log.Printf("Syncing %d blocks from height %d to %d", endHeight-startHeight+1, startHeight, endHeight)
+	}
 
-		for h := startHeight; h <= endHeight; h += MAX_BLOCK_FLUSH {
-			endBlock := h + MAX_BLOCK_FLUSH
-			if endBlock > endHeight {
-				endBlock = endHeight
-			}
+	for h := startHeight; h <= endHeight; {
+		err := i.IndexBlocks(h, endHeight)
 
-			err := i.IndexBlocks(h, endBlock)
-			if err != nil {
-				return err
-			}
-			// Increment i after the block is indexed
-			h++
+		reorgErr := &ReorgError{}
+		if !errors.As(err, &reorgErr) {
+			return err
 		}
-	} else {
-		// Just a few blocks to add, sync one by one
-		for h := startHeight; h <= endHeight; h++ {
-			log.Printf("Indexing block %d", h)
-
-			// Check for reorg before indexing each block
-			reorgHeight, err := i.Reorg(h, REORG_DEPTH_CHECK)
-			if err != nil {
-				return err
-			}
 
-			if reorgHeight > 0 {
-				h = reorgHeight
-			}
+		// the blocks before the discontinuity are flushed, find where the chains fork
+		reorgHeight, err := i.Reorg(reorgErr.Height, REORG_DEPTH_CHECK)
+		if err != nil {
+			return err
+		}
 
-			err = i.IndexBlocks(h, h)
-			if err != nil {
-				return err
-			}
+		h = reorgErr.Height
+		if reorgHeight > 0 {
+			h = reorgHeight
 		}
 	}
+
 	return nil
 }
 
-func (i *Indexer) flush(blockHeight int32, newBlocks *[]db.Block, newTxs *[]db.Transaction, newVins *[]db.Vin, newVouts *[]db.Vout) error {
+func (i *Indexer) flush(blockHeight int32, newBlocks *[]db.Block, newTxs *[]db.Transaction, newVins *[]db.Vin, newVouts *[]db.Vout, newHistory *[]db.AddressHistory, newRunestones *[]db.Runestone, newInscriptions *[]db.Inscription) error {
 	log.Printf("Flushing blocks to DB. Last block: %d", blockHeight)
-	// TODO: it might be possible to run these async using waitgroup
-	err := i.DbRepo.CreateBlocks(newBlocks)
-	if err != nil {
-		return err
-	}
 
-	err = i.DbRepo.CreateTransactions(newTxs)
-	if err != nil {
-		return err
-	}
+	// all or nothing, so a failed flush doesn't leave a partial block behind
+	commitMessage := fmt.Sprintf("Indexed block %d", blockHeight)
+	err := i.DbRepo.CommitTransaction(commitMessage, func(tx *gorm.DB) error {
+		txRepo := db.NewDBRepository(tx)
+
+		err := txRepo.CreateBlocks(newBlocks)
+		if err != nil {
+			return err
+		}
 
-	err = i.DbRepo.CreateVins(newVins)
+		err = txRepo.CreateTransactions(newTxs)
+		if err != nil {
+			return err
+		}
+
+		err = txRepo.CreateVins(newVins)
+		if err != nil {
+			return err
+		}
+
+		// update outpoint spending, vouts created in this batch are marked before insert
+		spentVins := markSpentVouts(*newVins, *newVouts)
+
+		err = txRepo.CreateVouts(newVouts)
+		if err != nil {
+			return err
+		}
+
+		err = txRepo.MarkVoutsSpent(spentVins)
+		if err != nil {
+			return err
+		}
+
+		err = txRepo.CreateAddressHistory(newHistory)
+		if err != nil {
+			return err
+		}
+
+		err = txRepo.CreateRunestones(newRunestones)
+		if err != nil {
+			return err
+		}
+
+		err = txRepo.CreateInscriptions(newInscriptions)
+		if err != nil {
+			return err
+		}
+
+		// the flushed transactions aren't pending anymore
+		err = txRepo.DeleteConfirmedMempoolTransactions((*newBlocks)[0].Height, (*newBlocks)[len(*newBlocks)-1].Height)
+		if err != nil {
+			return err
+		}
+
+		return txRepo.SetLastHeight(blockHeight)
+	})
 	if err != nil {
 		return err
 	}
 
-	err = i.DbRepo.CreateVouts(newVouts)
-	if err != nil {
-		return err
+	i.Status.indexed(blockHeight, (*newBlocks)[len(*newBlocks)-1].Hash, time.Now())
+
+	return i.prune(blockHeight)
+}
+
+// markSpentVouts marks the vouts spent by vins, returns the vins spending vouts from previous batches
+func markSpentVouts(vins []db.Vin, vouts []db.Vout) []db.Vin {
+	voutsIndex := make(map[string]int, len(vouts))
+	for idx, vout := range vouts {
+		voutsIndex[fmt.Sprintf("%s:%d", vout.TxHash, vout.TxIndex)] = idx
 	}
 
-	// update outpoint spending
-	err = i.DbRepo.SetLastHeight(blockHeight)
-	if err != nil {
-		return err
+	spentVins := []db.Vin{}
+	for _, vin := range vins {
+		if vin.FundingTxHash == "" {
+			continue
+		}
+
+		idx, ok := voutsIndex[fmt.Sprintf("%s:%d", vin.FundingTxHash, vin.FundingTxIndex)]
+		if !ok {
+			spentVins = append(spentVins, vin)
+			continue
+		}
+
+		vouts[idx].SpendingTxHash = vin.TxHash
+		vouts[idx].SpendingTxIndex = vin.TxIndex
+		vouts[idx].SpendingBlockHeight = vin.BlockHeight
 	}
-	commitMessage := fmt.Sprintf("Indexed block %d", blockHeight)
-	i.DbRepo.Db.Exec("CALL DOLT_COMMIT('--allow-empty', '-Am', ?);", commitMessage)
 
-	return nil
+	return spentVins
 }
 
+// IndexBlocks indexes the blocks from fromBlockHeight to toBlockHeight, checking that every block
+// extends the previous one. On a discontinuity the blocks before it are flushed and a ReorgError is returned.
 func (i *Indexer) IndexBlocks(fromBlockHeight int32, toBlockHeight int32) error {
 	log.Printf("index new blocks from %d to %d", fromBlockHeight, toBlockHeight)
 
-	blockHeight := fromBlockHeight
-	blockHash, err := i.bitcoinRepo.GetBlockHash(fromBlockHeight)
-	if err != nil {
-		return err
-	}
-
 	newBlocks := []db.Block{}
 	newTxs := []db.Transaction{}
 	newVins := []db.Vin{}
 	newVouts := []db.Vout{}
+	newHistory := []db.AddressHistory{}
+	newRunestones := []db.Runestone{}
+	newInscriptions := []db.Inscription{}
+	i.pendingVouts = nil
+
+	// the hash the next block must point to, unknown if nothing is indexed before fromBlockHeight
+	previousHash := ""
+	if fromBlockHeight > 0 {
+		previousBlock, err := i.DbRepo.GetBlockByHeight(int64(fromBlockHeight - 1))
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil {
+			previousHash = previousBlock.Hash
+		}
+	}
 
-	for {
-		// Break if toBlockHeight is empty (0) or if we've reached it
-		if blockHeight > toBlockHeight {
-			break
+	flush := func(height int32) error {
+		if len(newBlocks) == 0 {
+			return nil
 		}
 
-		block, err := i.bitcoinRepo.GetBlock(blockHash)
+		err := i.flush(height, &newBlocks, &newTxs, &newVins, &newVouts, &newHistory, &newRunestones, &newInscriptions)
 		if err != nil {
 			return err
 		}
+		newBlocks = []db.Block{}
+		newTxs = []db.Transaction{}
+		newVins = []db.Vin{}
+		newVouts = []db.Vout{}
+		newHistory = []db.AddressHistory{}
+		newRunestones = []db.Runestone{}
+		newInscriptions = []db.Inscription{}
+		i.pendingVouts = nil
+
+		return nil
+	}
 
-		err = i.HandleBlock(blockHeight, block, &newBlocks, &newTxs, &newVins, &newVouts)
-		if err != nil {
-			return err
+	done := make(chan struct{})
+	defer close(done)
+
+	for fetched := range i.fetcher.Fetch(fromBlockHeight, toBlockHeight, done) {
+		if fetched.Err != nil {
+			return fetched.Err
 		}
 
-		// Flush data if we've reached toBlockHeight
-		if blockHeight == toBlockHeight {
-			err = i.flush(blockHeight, &newBlocks, &newTxs, &newVins, &newVouts)
-			if err != nil {
+		if previousHash != "" && fetched.Block.Previousblockhash != previousHash {
+			if err := flush(fetched.Height - 1); err != nil {
 				return err
 			}
-			newBlocks = []db.Block{}
-			newTxs = []db.Transaction{}
-			newVins = []db.Vin{}
-			newVouts = []db.Vout{}
+
+			return &ReorgError{fetched.Height}
 		}
+		previousHash = fetched.Block.Hash
 
-		i := 10
+		err := i.HandleBlock(fetched.Height, fetched.Block, &newBlocks, &newTxs, &newVins, &newVouts, &newHistory, &newRunestones, &newInscriptions)
+		if err != nil {
+			return err
+		}
 
-		if os.Getenv("INDEXER_SLEEP_TIME") != "" {
-			i, err = strconv.Atoi(os.Getenv("INDEXER_SLEEP_TIME"))
-			if err != nil {
+		// Flush data every MAX_BLOCK_FLUSH blocks and once we've reached toBlockHeight
+		if fetched.Height == toBlockHeight || len(newBlocks) >= MAX_BLOCK_FLUSH {
+			if err := flush(fetched.Height); err != nil {
 				return err
 			}
 		}
-
-		// Sleep because of RPC rate limit
-		time.Sleep(time.Duration(i) * time.Millisecond)
-
-		blockHeight++
-		blockHash = block.Nextblockhash
-		if blockHash == "" {
-			break // End of chain reached
-		}
 	}
 
 	return nil
 }
 
-func (i *Indexer) HandleBlock(blockHeight int32, block *bitcoin.GetBlock, newBlocks *[]db.Block, newTxs *[]db.Transaction, newVins *[]db.Vin, newVout *[]db.Vout) error {
+func (i *Indexer) HandleBlock(blockHeight int32, block *bitcoin.GetBlock, newBlocks *[]db.Block, newTxs *[]db.Transaction, newVins *[]db.Vin, newVout *[]db.Vout, newHistory *[]db.AddressHistory, newRunestones *[]db.Runestone, newInscriptions *[]db.Inscription) error {
 	log.Printf("handle block height %d, hash %s", blockHeight, block.Hash)
 
 	// insert block
@@ -178,26 +300,40 @@ func (i *Indexer) HandleBlock(blockHeight int32, block *bitcoin.GetBlock, newBlo
 	}
 	*newBlocks = append(*newBlocks, newBlock)
 
+	return i.handleTransactions(blockHeight, block, newTxs, newVins, newVout, newHistory, newRunestones, newInscriptions)
+}
+
+// handleTransactions appends the rows of the block transactions, mempool transactions are handled
+// as a block without hash and height
+func (i *Indexer) handleTransactions(blockHeight int32, block *bitcoin.GetBlock, newTxs *[]db.Transaction, newVins *[]db.Vin, newVout *[]db.Vout, newHistory *[]db.AddressHistory, newRunestones *[]db.Runestone, newInscriptions *[]db.Inscription) error {
 	// fill the txhash using txid instead of txhash, for the witness tx the id is different from the hash
 	// https://bitcoin.stackexchange.com/questions/77699/whats-the-difference-between-txid-and-hash-getrawtransaction-bitcoind
 
+	prevouts, err := i.prefetchPrevouts(block, *newVout)
+	if err != nil {
+		return err
+	}
+
 	for txIdx, transaction := range block.Tx {
 		// insert transaction
 		newTx := db.Transaction{
 			Hash:        transaction.Txid,
 			LockTime:    uint32(transaction.Locktime),
 			Version:     int32(transaction.Version),
+			Hex:         transaction.Hex,
 			Safe:        false,
 			BlockHash:   block.Hash,
 			BlockHeight: uint64(blockHeight),
 			BlockIndex:  uint32(txIdx),
 		}
 		*newTxs = append(*newTxs, newTx)
+		txVouts := len(*newVout)
+		txVins := len(*newVins)
 
 		// vouts
 		for voutIdx, vout := range transaction.Vout {
-			// convert btc value into sat, 1 btc is 100_000_000 sats
-			satValue := int64(vout.Value * 100_000_000)
+			// values are decoded in sats
+			satValue := int64(vout.Value)
 			vout := db.Vout{
 				TxHash:       transaction.Txid,
 				TxIndex:      uint32(voutIdx),
@@ -206,14 +342,37 @@ func (i *Indexer) HandleBlock(blockHeight int32, block *bitcoin.GetBlock, newBlo
 				BlockTxIndex: uint32(txIdx),
 				PkScript:     vout.ScriptPubKey.Hex,
 				Value:        satValue,
-				Spender:      vout.ScriptPubKey.Address,
+				Spender:      i.scriptAddress(vout.ScriptPubKey.Hex, vout.ScriptPubKey.Address),
+				Type:         bitcoin.ClassifyScript(vout.ScriptPubKey.Hex),
 			}
+			vout.SetAsmScripts()
 			*newVout = append(*newVout, vout)
 		}
 
 		// vins
 		for idxx, vin := range transaction.Vin {
-			satValue := int64(vin.PrevOutput.Value * 100_000_000)
+			satValue := int64(vin.PrevOutput.Value)
+			pkScript := vin.PrevOutput.ScriptPubKey.Hex
+			spender := i.scriptAddress(pkScript, vin.PrevOutput.ScriptPubKey.Address)
+
+			// raw blocks don't include the prevouts
+			if vin.Coinbase == "" && pkScript == "" {
+				prevout, err := i.resolvePrevout(vin.Txid, uint32(vin.Vout), *newVout, prevouts)
+				if err != nil {
+					return err
+				}
+				if prevout != nil {
+					satValue = prevout.Value
+					pkScript = prevout.PkScript
+					spender = prevout.Spender
+				}
+			}
+
+			scriptType := ""
+			if vin.Coinbase == "" {
+				scriptType = bitcoin.ClassifyScript(pkScript)
+			}
+
 			vin := db.Vin{
 				TxHash:          transaction.Txid,
 				TxIndex:         uint32(idxx),
@@ -226,51 +385,226 @@ func (i *Indexer) HandleBlock(blockHeight int32, block *bitcoin.GetBlock, newBlo
 				FundingTxHash:  vin.Txid,
 				FundingTxIndex: uint32(vin.Vout),
 
-				PkScript: vin.PrevOutput.ScriptPubKey.Hex,
+				PkScript: pkScript,
 				Value:    satValue,
-				Spender:  vin.PrevOutput.ScriptPubKey.Address,
+				Spender:  spender,
+				Type:     scriptType,
 
-				Witness: strings.Join(vin.Txinwitness, ","),
+				Witness:      strings.Join(vin.Txinwitness, ","),
+				WitnessStack: vin.Txinwitness,
 			}
+			vin.SetAsmScripts()
 			*newVins = append(*newVins, vin)
 		}
+
+		*newHistory = append(*newHistory, addressHistory((*newVins)[txVins:], (*newVout)[txVouts:])...)
+
+		if runestone := runes.Decipher(&transaction); runestone != nil {
+			*newRunestones = append(*newRunestones, db.Runestone{
+				TxHash:       transaction.Txid,
+				BlockHash:    block.Hash,
+				BlockHeight:  uint64(blockHeight),
+				BlockTxIndex: uint32(txIdx),
+				Edicts:       runestone.Edicts,
+				Etching:      runestone.Etching,
+				Mint:         runestone.Mint,
+				Pointer:      runestone.Pointer,
+				Cenotaph:     runestone.Cenotaph,
+				Flaw:         runestone.Flaw,
+			})
+		}
+
+		for _, inscription := range ordinals.ParseInscriptions(&transaction) {
+			*newInscriptions = append(*newInscriptions, db.Inscription{
+				Id:                    inscription.Id,
+				TxHash:                transaction.Txid,
+				BlockHash:             block.Hash,
+				BlockHeight:           uint64(blockHeight),
+				BlockTxIndex:          uint32(txIdx),
+				Input:                 inscription.Input,
+				Offset:                inscription.Offset,
+				ContentType:           inscription.ContentType,
+				ContentEncoding:       inscription.ContentEncoding,
+				Body:                  inscription.Body,
+				Parents:               inscription.Parents,
+				Delegate:              inscription.Delegate,
+				Pointer:               inscription.Pointer,
+				Metaprotocol:          inscription.Metaprotocol,
+				Metadata:              inscription.Metadata,
+				Pushnum:               inscription.Pushnum,
+				Stutter:               inscription.Stutter,
+				DuplicateField:        inscription.DuplicateField,
+				IncompleteField:       inscription.IncompleteField,
+				UnrecognizedEvenField: inscription.UnrecognizedEvenField,
+			})
+		}
 	}
 
 	return nil
 }
 
+// scriptAddress derives the address of a script for the indexer's network, falling back to the
+// address returned by the block source for scripts without a standard address
+func (i *Indexer) scriptAddress(pkScript string, sourceAddress string) string {
+	if address := bitcoin.ScriptAddress(pkScript, i.network); address != "" {
+		return address
+	}
+
+	return sourceAddress
+}
+
+// addressHistory sums what each address received and sent in a transaction
+func addressHistory(vins []db.Vin, vouts []db.Vout) []db.AddressHistory {
+	history := []db.AddressHistory{}
+	indexes := map[string]int{}
+
+	entry := func(address string, txHash string, blockHash string, blockHeight uint64, blockTxIndex uint32) *db.AddressHistory {
+		idx, ok := indexes[address]
+		if !ok {
+			idx = len(history)
+			indexes[address] = idx
+			history = append(history, db.AddressHistory{
+				Address:      address,
+				TxHash:       txHash,
+				BlockHash:    blockHash,
+				BlockHeight:  blockHeight,
+				BlockTxIndex: blockTxIndex,
+			})
+		}
+
+		return &history[idx]
+	}
+
+	for _, vin := range vins {
+		if vin.Spender != "" {
+			entry(vin.Spender, vin.TxHash, vin.BlockHash, vin.BlockHeight, vin.BlockTxIndex).Sent += vin.Value
+		}
+	}
+
+	for _, vout := range vouts {
+		if vout.Spender != "" {
+			entry(vout.Spender, vout.TxHash, vout.BlockHash, vout.BlockHeight, vout.BlockTxIndex).Received += vout.Value
+		}
+	}
+
+	return history
+}
+
+// indexPendingVouts maps the outpoints of the vouts appended since the last call to their index in newVouts
+func (i *Indexer) indexPendingVouts(newVouts []db.Vout) {
+	if i.pendingVouts == nil {
+		i.pendingVouts = map[string]int{}
+		i.indexedVouts = 0
+	}
+
+	for ; i.indexedVouts < len(newVouts); i.indexedVouts++ {
+		vout := newVouts[i.indexedVouts]
+		i.pendingVouts[db.OutpointKey(vout.TxHash, vout.TxIndex)] = i.indexedVouts
+	}
+}
+
+// prefetchPrevouts loads the flushed vouts spent by the vins of block missing their prevout (raw blocks)
+// in batched queries, instead of one query per vin
+func (i *Indexer) prefetchPrevouts(block *bitcoin.GetBlock, newVouts []db.Vout) (map[string]*db.Vout, error) {
+	i.indexPendingVouts(newVouts)
+
+	outpoints := [][]interface{}{}
+	for _, transaction := range block.Tx {
+		for _, vin := range transaction.Vin {
+			if vin.Coinbase != "" || vin.PrevOutput.ScriptPubKey.Hex != "" {
+				continue
+			}
+			if _, ok := i.pendingVouts[db.OutpointKey(vin.Txid, uint32(vin.Vout))]; ok {
+				continue
+			}
+			outpoints = append(outpoints, []interface{}{vin.Txid, uint32(vin.Vout)})
+		}
+	}
+
+	if len(outpoints) == 0 {
+		return map[string]*db.Vout{}, nil
+	}
+
+	return i.DbRepo.GetVoutsByOutpoints(outpoints)
+}
+
+// resolvePrevout finds the vout spent by a vin, in the vouts not flushed yet or in the prefetched ones.
+// It's nil if the vout is older than the configured start height
+func (i *Indexer) resolvePrevout(txHash string, txIndex uint32, newVouts []db.Vout, prevouts map[string]*db.Vout) (*db.Vout, error) {
+	i.indexPendingVouts(newVouts)
+
+	key := db.OutpointKey(txHash, txIndex)
+	if idx, ok := i.pendingVouts[key]; ok {
+		return &newVouts[idx], nil
+	}
+
+	vout, ok := prevouts[key]
+	if !ok {
+		// outputs created before the start height were never indexed
+		if i.retention.StartHeight > 0 {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("prevout %s not found", key)
+	}
+
+	return vout, nil
+}
+
+// CheckTip compares the indexed block at height with the bitcoin node, and reorgs if they differ
+func (i *Indexer) CheckTip(height int32) (bool, error) {
+	reorgHeight, err := i.Reorg(height+1, REORG_DEPTH_CHECK)
+	return reorgHeight > 0, err
+}
+
+// ErrReorgBelowPruned is returned for a fork below the pruned or the start height, the vins and
+// spent vouts a rollback needs are gone so the indexer has to be resynced
+var ErrReorgBelowPruned = errors.New("reorg below the pruned height")
+
+// FindReorgHeight returns the first height below fromHeight where the indexed chain differs from the
+// bitcoin node, or 0 if the block at fromHeight-1 matches. Forks deeper than depth are found too,
+// down to the pruned height in prune mode and the start height
 func (i *Indexer) FindReorgHeight(fromHeight int32, depth int32) (int32, error) {
-	if fromHeight == 0 {
-		return 0, nil
+	prunedHeight, err := i.DbRepo.GetPrunedHeight()
+	if err != nil {
+		return 0, err
 	}
+	// the rollback keeps the common block, so it can be the last pruned one
+	lowestHeight := max(0, prunedHeight, i.retention.StartHeight)
 
-	for currentHeight := fromHeight; currentHeight > 0 && currentHeight >= fromHeight-depth; currentHeight-- {
-		// Get block from Bitcoin node
-		btcBlockHash, err := i.bitcoinRepo.GetBlockHash(int32(currentHeight))
-		if err != nil {
-			return 0, fmt.Errorf("failed to get block from Bitcoin node at height %d: %w", currentHeight, err)
+	for currentHeight := fromHeight - 1; currentHeight >= lowestHeight; currentHeight-- {
+		if currentHeight == fromHeight-depth-1 {
+			log.Printf("Reorg deeper than %d blocks from height %d", depth, fromHeight)
 		}
 
-		btcBlock, err := i.bitcoinRepo.GetBlockWithVerbosity(btcBlockHash, 2)
+		// Get block from Bitcoin node
+		btcBlockHash, err := i.bitcoinRepo.GetBlockHash(currentHeight)
 		if err != nil {
 			return 0, fmt.Errorf("failed to get block from Bitcoin node at height %d: %w", currentHeight, err)
 		}
 
-		// Get previous block from our database
-		dbBlock, err := i.DbRepo.GetBlockByHeight(int64(currentHeight - 1))
+		// Get block from our database
+		dbBlock, err := i.DbRepo.GetBlockByHeight(int64(currentHeight))
+		if errors.Is(err, gorm.ErrRecordNotFound) && currentHeight == fromHeight-1 {
+			return 0, nil // Nothing indexed to compare with
+		}
 		if err != nil {
 			return 0, fmt.Errorf("failed to get block from DB at height %d: %w", currentHeight, err)
 		}
 
-		if btcBlock.Previousblockhash == dbBlock.Hash {
-			if currentHeight == fromHeight {
+		if btcBlockHash == dbBlock.Hash {
+			if currentHeight == fromHeight-1 {
 				return 0, nil // No reorg detected
 			}
-			return currentHeight, nil // Reorg starts at this height
+			return currentHeight + 1, nil // Reorg starts at this height
 		}
 	}
 
-	return 0, nil // No reorg detected within the specified depth
+	if lowestHeight > 0 {
+		return 0, fmt.Errorf("%w: no common block with the Bitcoin node from height %d to %d", ErrReorgBelowPruned, lowestHeight, fromHeight-1)
+	}
+
+	return 0, errors.New("no common block with the Bitcoin node")
 }
 
 func (i *Indexer) Reorg(fromHeight int32, depth int32) (int32, error) {
@@ -288,15 +622,30 @@ func (i *Indexer) Reorg(fromHeight int32, depth int32) (int32, error) {
 
 	log.Printf("Reorg detected at height %d. Starting reorganization process.", reorgHeight)
 
-	// Delete blocks from reorg height onwards
-	err = i.DbRepo.UpdateBlocksAsOrphan(reorgHeight)
+	// Orphan blocks from reorg height onwards and remove their transactions
+	err = i.DbRepo.RollbackToHeight(reorgHeight-1, fromHeight-reorgHeight)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete blocks from height %d: %w", reorgHeight, err)
+		return 0, fmt.Errorf("failed to rollback blocks from height %d: %w", reorgHeight, err)
+	}
+	i.Status.reorged(reorgHeight, fromHeight-reorgHeight)
+
+	return reorgHeight, nil
+}
+
+// SyncStatus returns the sync progress, the last hash is read from the DB if nothing was flushed since startup
+func (i *Indexer) SyncStatus() (*SyncStatus, error) {
+	status := i.Status.Get(time.Now())
+	if status.LastHash != "" || status.LastHeight < 0 {
+		return &status, nil
 	}
-	err = i.DbRepo.SetLastHeight(reorgHeight - 1)
+
+	hash, err := i.DbRepo.GetBlockHashByHeight(uint64(status.LastHeight))
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete blocks from height %d: %w", reorgHeight, err)
+		return nil, err
+	}
+	if hash != nil {
+		status.LastHash = *hash
 	}
 
-	return reorgHeight, nil
+	return &status, nil
 }
@@ -4,9 +4,12 @@ import (
 	"eastnode/indexer/repository/bitcoin"
 	"eastnode/indexer/repository/db"
 	utils "eastnode/utils/store"
+	"errors"
+	"fmt"
 	"log"
 	"os"
 	"testing"
+	"time"
 )
 
 func clearIndexerTest() {
@@ -156,3 +159,438 @@ func TestScheduler_ReorgSimulation(t *testing.T) {
 
 	clearIndexerTest()
 }
+
+func TestScheduler_DeepReorg(t *testing.T) {
+	clearIndexerTest()
+
+	instance := utils.GetFakeInstance(utils.IndexerDB, "../utils/store/test/doltdump.sql")
+	dbRepo := db.NewDBRepository(instance.Gorm)
+	mockBitcoinRepo := bitcoin.NewMockBitcoinRepo()
+
+	indexer := NewIndexer(dbRepo, mockBitcoinRepo)
+
+	for i := 0; i <= 20; i++ {
+		mockBitcoinRepo.AddOrReplaceBlock(int32(i))
+	}
+
+	err := indexer.SyncBlocks(0, 20)
+	if err != nil {
+		t.Fatalf("Failed to sync initial blocks: %v", err)
+	}
+
+	// Reorg deeper than REORG_DEPTH_CHECK, the replacement blocks reuse the same txids
+	for i := 5; i <= 21; i++ {
+		mockBitcoinRepo.AddOrReplaceBlock(int32(i))
+	}
+
+	err = indexer.SyncBlocks(21, 21)
+	if err != nil {
+		t.Fatalf("Failed to sync blocks after reorg: %v", err)
+	}
+
+	for i := 5; i <= 21; i++ {
+		blockHash, _ := mockBitcoinRepo.GetBlockHash(int32(i))
+		indexedBlock, err := indexer.DbRepo.GetBlockByHeight(int64(i))
+		if err != nil {
+			t.Fatalf("Failed to get indexed block for height %d: %v", i, err)
+		}
+
+		if indexedBlock.Hash != blockHash {
+			t.Errorf("Block hash mismatch at height %d. Expected: %s, Got: %s", i, blockHash, indexedBlock.Hash)
+		}
+
+		block, _ := mockBitcoinRepo.GetBlock(blockHash)
+		transactions, err := indexer.DbRepo.GetTransactionsByBlockHash(blockHash)
+		if err != nil {
+			t.Fatalf("Failed to get transactions for height %d: %v", i, err)
+		}
+
+		if len(transactions) != len(block.Tx) {
+			t.Errorf("Expected %d transactions at height %d, got %d", len(block.Tx), i, len(transactions))
+		}
+	}
+
+	var orphanTxs int64
+	indexer.DbRepo.Db.Model(&db.Transaction{}).Where("block_hash NOT IN (SELECT hash FROM blocks WHERE is_orphan = ?)", false).Count(&orphanTxs)
+	if orphanTxs != 0 {
+		t.Errorf("Expected orphaned transactions to be removed, got %d", orphanTxs)
+	}
+
+	// read by the status of the JSON-RPC server
+	reorgHeight, reorgDepth, err := indexer.DbRepo.GetLastReorg()
+	if err != nil || reorgHeight != 5 || reorgDepth <= 0 {
+		t.Errorf("Last reorg incorrect, height %d depth %d: %v", reorgHeight, reorgDepth, err)
+	}
+
+	clearIndexerTest()
+}
+
+func TestScheduler_ReorgBelowPruned(t *testing.T) {
+	clearIndexerTest()
+	t.Setenv("INDEXER_MODE", INDEXER_MODE_PRUNE)
+	t.Setenv("INDEXER_PRUNE_DEPTH", "10")
+
+	instance := utils.GetFakeInstance(utils.IndexerDB, "../utils/store/test/doltdump.sql")
+	dbRepo := db.NewDBRepository(instance.Gorm)
+	mockBitcoinRepo := bitcoin.NewMockBitcoinRepo()
+
+	indexer := NewIndexer(dbRepo, mockBitcoinRepo)
+
+	for i := 0; i <= 30; i++ {
+		mockBitcoinRepo.AddOrReplaceBlock(int32(i))
+	}
+
+	if err := indexer.SyncBlocks(0, 30); err != nil {
+		t.Fatalf("Failed to sync initial blocks: %v", err)
+	}
+
+	// pruned up to 20, a reorg within the prune depth still rolls back
+	for i := 25; i <= 31; i++ {
+		mockBitcoinRepo.AddOrReplaceBlock(int32(i))
+	}
+	if err := indexer.SyncBlocks(31, 31); err != nil {
+		t.Fatalf("Failed to sync blocks after reorg: %v", err)
+	}
+
+	// the fork is below the pruned height
+	for i := 15; i <= 32; i++ {
+		mockBitcoinRepo.AddOrReplaceBlock(int32(i))
+	}
+	if err := indexer.SyncBlocks(32, 32); !errors.Is(err, ErrReorgBelowPruned) {
+		t.Errorf("Expected a reorg below the pruned height, got %v", err)
+	}
+
+	clearIndexerTest()
+}
+
+func TestScheduler_ReorgDuringBulkSync(t *testing.T) {
+	clearIndexerTest()
+
+	instance := utils.GetFakeInstance(utils.IndexerDB, "../utils/store/test/doltdump.sql")
+	dbRepo := db.NewDBRepository(instance.Gorm)
+	mockBitcoinRepo := bitcoin.NewMockBitcoinRepo()
+
+	indexer := NewIndexer(dbRepo, mockBitcoinRepo)
+
+	for i := 0; i <= 10; i++ {
+		mockBitcoinRepo.AddOrReplaceBlock(int32(i))
+	}
+
+	err := indexer.SyncBlocks(0, 10)
+	if err != nil {
+		t.Fatalf("Failed to sync initial blocks: %v", err)
+	}
+
+	// more than MAX_BLOCK_FLUSH blocks on top of a fork at height 9
+	endHeight := 9 + MAX_BLOCK_FLUSH + 10
+	for i := 9; i <= endHeight; i++ {
+		mockBitcoinRepo.AddOrReplaceBlock(int32(i))
+	}
+
+	err = indexer.SyncBlocks(11, int32(endHeight))
+	if err != nil {
+		t.Fatalf("Failed to sync blocks after reorg: %v", err)
+	}
+
+	for i := 8; i <= endHeight; i++ {
+		blockHash, _ := mockBitcoinRepo.GetBlockHash(int32(i))
+		indexedBlock, err := indexer.DbRepo.GetBlockByHeight(int64(i))
+		if err != nil {
+			t.Fatalf("Failed to get indexed block for height %d: %v", i, err)
+		}
+
+		if indexedBlock.Hash != blockHash {
+			t.Errorf("Block hash mismatch at height %d. Expected: %s, Got: %s", i, blockHash, indexedBlock.Hash)
+		}
+	}
+
+	lastHeight, _ := indexer.DbRepo.GetLastHeight()
+	if lastHeight != int32(endHeight) {
+		t.Errorf("Expected last height %d, got %d", endHeight, lastHeight)
+	}
+
+	clearIndexerTest()
+}
+
+func TestMarkSpentVouts(t *testing.T) {
+	vouts := []db.Vout{
+		{TxHash: "a", TxIndex: 0, BlockHeight: 1},
+		{TxHash: "a", TxIndex: 1, BlockHeight: 1},
+	}
+	vins := []db.Vin{
+		{TxHash: "coinbase", TxIndex: 0, BlockHeight: 2},
+		{TxHash: "b", TxIndex: 0, BlockHeight: 2, FundingTxHash: "a", FundingTxIndex: 1},
+		{TxHash: "b", TxIndex: 1, BlockHeight: 2, FundingTxHash: "old", FundingTxIndex: 3},
+	}
+
+	spentVins := markSpentVouts(vins, vouts)
+
+	if vouts[0].SpendingTxHash != "" {
+		t.Errorf("Vout a:0 should be unspent, spent by %s", vouts[0].SpendingTxHash)
+	}
+
+	if vouts[1].SpendingTxHash != "b" || vouts[1].SpendingTxIndex != 0 || vouts[1].SpendingBlockHeight != 2 {
+		t.Errorf("Vout a:1 should be spent by b:0 at height 2, got %+v", vouts[1])
+	}
+
+	if len(spentVins) != 1 || spentVins[0].FundingTxHash != "old" {
+		t.Errorf("Expected only the vin spending a previous batch, got %+v", spentVins)
+	}
+}
+
+func TestAddressHistory(t *testing.T) {
+	vins := []db.Vin{
+		{TxHash: "b", Spender: "alice", Value: 100},
+		{TxHash: "b", Spender: "alice", Value: 50},
+		{TxHash: "b"},
+	}
+	vouts := []db.Vout{
+		{TxHash: "b", Spender: "bob", Value: 120},
+		{TxHash: "b", Spender: "alice", Value: 20},
+		{TxHash: "b", Value: 0},
+	}
+
+	history := addressHistory(vins, vouts)
+
+	if len(history) != 2 {
+		t.Fatalf("Expected history for 2 addresses, got %+v", history)
+	}
+
+	if history[0].Address != "alice" || history[0].Sent != 150 || history[0].Received != 20 {
+		t.Errorf("History of alice incorrect %+v", history[0])
+	}
+
+	if history[1].Address != "bob" || history[1].Sent != 0 || history[1].Received != 120 {
+		t.Errorf("History of bob incorrect %+v", history[1])
+	}
+}
+
+func TestHandleBlock_ScriptTypes(t *testing.T) {
+	indexer := &Indexer{network: "mainnet"}
+	block := &bitcoin.GetBlock{Hash: "block", Tx: []bitcoin.Tx{{
+		Txid: "coinbase",
+		Vin:  []bitcoin.Vin{{Coinbase: "51"}},
+		Vout: []bitcoin.Vout{
+			// the source address is only used for scripts without a standard address
+			{ScriptPubKey: bitcoin.ScriptPubKey{Hex: "0014751e76e8199196d454941c45d1b3a323f1433bd6", Address: "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080"}},
+			{ScriptPubKey: bitcoin.ScriptPubKey{Hex: "6a0401020304"}},
+		},
+	}}}
+
+	newVouts := []db.Vout{}
+	err := indexer.HandleBlock(1, block, &[]db.Block{}, &[]db.Transaction{}, &[]db.Vin{}, &newVouts, &[]db.AddressHistory{}, &[]db.Runestone{}, &[]db.Inscription{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if newVouts[0].Type != bitcoin.SCRIPT_TYPE_P2WPKH || newVouts[0].Spender != "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4" {
+		t.Errorf("P2wpkh vout incorrect %+v", newVouts[0])
+	}
+
+	if newVouts[1].Type != bitcoin.SCRIPT_TYPE_OP_RETURN || newVouts[1].Spender != "" {
+		t.Errorf("Op return vout incorrect %+v", newVouts[1])
+	}
+}
+
+func TestRetentionFromEnv(t *testing.T) {
+	retention, err := RetentionFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retention.Mode != INDEXER_MODE_ARCHIVE || retention.StartHeight != 0 || retention.pruneHeight(1000) != -1 {
+		t.Errorf("Default retention incorrect %+v", retention)
+	}
+
+	t.Setenv("INDEXER_MODE", INDEXER_MODE_PRUNE)
+	t.Setenv("INDEXER_PRUNE_DEPTH", "100")
+	t.Setenv("INDEXER_START_HEIGHT", "840000")
+	retention, err = RetentionFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retention.StartHeight != 840000 || retention.pruneHeight(840150) != 840050 || retention.pruneHeight(50) != -1 {
+		t.Errorf("Prune retention incorrect %+v", retention)
+	}
+
+	// a reorg could need the pruned vins
+	t.Setenv("INDEXER_PRUNE_DEPTH", "2")
+	if _, err := RetentionFromEnv(); err == nil {
+		t.Error("Prune depth below the reorg depth should fail")
+	}
+
+	t.Setenv("INDEXER_PRUNE_DEPTH", "100")
+	t.Setenv("INDEXER_MODE", "full")
+	if _, err := RetentionFromEnv(); err == nil {
+		t.Error("Unknown mode should fail")
+	}
+}
+
+func TestPruneToHeight_History(t *testing.T) {
+	clearIndexerTest()
+
+	instance := utils.GetFakeInstance(utils.IndexerDB, "../utils/store/test/doltdump.sql")
+	dbRepo := db.NewDBRepository(instance.Gorm)
+
+	for height := 1; height <= 10; height++ {
+		vin := db.Vin{TxHash: fmt.Sprintf("tx_%d", height), BlockHeight: uint64(height), AsmVersion: db.SCRIPT_ASM_VERSION}
+		if err := dbRepo.Db.Create(&vin).Error; err != nil {
+			t.Fatalf("Failed to create vin: %v", err)
+		}
+		dbRepo.Db.Exec("CALL DOLT_COMMIT('--allow-empty', '-Am', ?);", fmt.Sprintf("Block %d", height))
+	}
+
+	if err := dbRepo.PruneToHeight(5); err != nil {
+		t.Fatalf("Failed to prune: %v", err)
+	}
+
+	var vins int64
+	dbRepo.Db.Model(&db.Vin{}).Count(&vins)
+	if vins != 5 {
+		t.Errorf("Expected 5 vins after pruning, got %d", vins)
+	}
+
+	// pruning only commits, the previous blocks can still be read from the history
+	var history int64
+	dbRepo.Db.Raw("SELECT COUNT(*) FROM dolt_history_vins WHERE block_height <= 5").Scan(&history)
+	if history == 0 {
+		t.Error("Pruning shouldn't rewrite the history")
+	}
+
+	var commits int64
+	dbRepo.Db.Raw("SELECT COUNT(*) FROM dolt_log").Scan(&commits)
+	if commits < 11 {
+		t.Errorf("Expected the block commits to be kept, got %d commits", commits)
+	}
+
+	if err := dbRepo.SquashHistory("Squash history"); err != nil {
+		t.Fatalf("Failed to squash the history: %v", err)
+	}
+
+	dbRepo.Db.Raw("SELECT COUNT(*) FROM dolt_history_vins WHERE block_height <= 5").Scan(&history)
+	if history != 0 {
+		t.Errorf("Pruned vins are kept in the squashed history, got %d rows", history)
+	}
+
+	dbRepo.Db.Raw("SELECT COUNT(*) FROM dolt_log").Scan(&commits)
+	if commits != 2 {
+		t.Errorf("Expected the root and the squashed commit, got %d commits", commits)
+	}
+
+	clearIndexerTest()
+}
+
+func TestDiffMempool(t *testing.T) {
+	// b was mined or replaced, d is new
+	removed, added := diffMempool([]string{"a", "b", "c"}, []string{"c", "d", "a"})
+	if len(removed) != 1 || removed[0] != "b" {
+		t.Errorf("Removed incorrect %v", removed)
+	}
+	if len(added) != 1 || added[0] != "d" {
+		t.Errorf("Added incorrect %v", added)
+	}
+
+	removed, added = diffMempool(nil, []string{"a"})
+	if len(removed) != 0 || len(added) != 1 {
+		t.Errorf("Empty mempool diff incorrect %v %v", removed, added)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	var unknown *Status
+	if unknown.Synced() {
+		t.Error("Unknown status should not be synced")
+	}
+
+	status := NewStatus()
+	start := time.Unix(1700000000, 0)
+
+	status.setHeights(0, 2000)
+	status.indexed(500, "a", start)
+	status.indexed(1000, "b", start.Add(100*time.Second))
+
+	got := status.Get(start.Add(100 * time.Second))
+	if got.Mode != SYNC_MODE_BULK || got.Synced || got.LastHeight != 1000 || got.LastHash != "b" {
+		t.Errorf("Bulk status incorrect %+v", got)
+	}
+	if got.BlocksPerSecond != 5 || got.EtaSeconds != 200 {
+		t.Errorf("Rate incorrect %+v", got)
+	}
+
+	// no flush in the window
+	stalled := status.Get(start.Add(SYNC_RATE_WINDOW + time.Hour))
+	if stalled.BlocksPerSecond != 0 || stalled.EtaSeconds != -1 {
+		t.Errorf("Stalled status incorrect %+v", stalled)
+	}
+
+	status.reorged(995, 6)
+	got = status.Get(start.Add(100 * time.Second))
+	if got.LastHeight != 994 || got.LastReorgHeight != 995 || got.LastReorgDepth != 6 || got.BlocksPerSecond != 0 {
+		t.Errorf("Reorg status incorrect %+v", got)
+	}
+
+	status.setHeights(2000, 2000)
+	if !status.Synced() || status.Get(start).Mode != SYNC_MODE_TIP || status.Get(start).EtaSeconds != 0 {
+		t.Errorf("Synced status incorrect %+v", status.Get(start))
+	}
+}
+
+func TestStatus_Polled(t *testing.T) {
+	status := NewStatus()
+	start := time.Unix(1700000000, 0)
+
+	status.polled(500, "a", 2000, 0, 0, start)
+	status.polled(500, "a", 2000, 0, 0, start.Add(50*time.Second))
+	status.polled(1000, "b", 2000, 0, 0, start.Add(100*time.Second))
+
+	got := status.Get(start.Add(100 * time.Second))
+	if got.Mode != SYNC_MODE_BULK || got.Synced || got.LastHeight != 1000 || got.LastHash != "b" {
+		t.Errorf("Polled status incorrect %+v", got)
+	}
+	if got.BlocksPerSecond != 5 || got.EtaSeconds != 200 {
+		t.Errorf("Polled rate incorrect %+v", got)
+	}
+
+	// the indexer rolled back
+	status.polled(995, "c", 2000, 995, 6, start.Add(110*time.Second))
+	if got := status.Get(start.Add(110 * time.Second)); got.LastHeight != 995 || got.BlocksPerSecond != 0 || got.LastReorgHeight != 995 || got.LastReorgDepth != 6 {
+		t.Errorf("Rollback status incorrect %+v", got)
+	}
+
+	status.polled(2000, "d", 2000, 995, 6, start.Add(200*time.Second))
+	if !status.Synced() || status.Get(start).Mode != SYNC_MODE_TIP {
+		t.Errorf("Synced status incorrect %+v", status.Get(start))
+	}
+}
+
+func TestHandleTransactions_Mempool(t *testing.T) {
+	indexer := &Indexer{network: "mainnet"}
+	tx := bitcoin.Tx{
+		Txid: "pending",
+		Vin: []bitcoin.Vin{{
+			Txid: "funding",
+			PrevOutput: bitcoin.Prevout{
+				Value:        1000,
+				ScriptPubKey: bitcoin.ScriptPubKey{Hex: "0014751e76e8199196d454941c45d1b3a323f1433bd6"},
+			},
+		}},
+		Vout: []bitcoin.Vout{{Value: 900, ScriptPubKey: bitcoin.ScriptPubKey{Hex: "0014751e76e8199196d454941c45d1b3a323f1433bd6"}}},
+	}
+
+	newTxs := []db.Transaction{}
+	newVins := []db.Vin{}
+	newVouts := []db.Vout{}
+	err := indexer.handleTransactions(0, &bitcoin.GetBlock{Tx: []bitcoin.Tx{tx}}, &newTxs, &newVins, &newVouts, &[]db.AddressHistory{}, &[]db.Runestone{}, &[]db.Inscription{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(newTxs) != 1 || newTxs[0].BlockHash != "" || newTxs[0].BlockHeight != 0 {
+		t.Errorf("Mempool transaction incorrect %+v", newTxs)
+	}
+	if len(newVins) != 1 || newVins[0].Value != 1000 || newVins[0].Spender != "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4" {
+		t.Errorf("Mempool vin incorrect %+v", newVins)
+	}
+	if len(newVouts) != 1 || newVouts[0].Value != 900 {
+		t.Errorf("Mempool vout incorrect %+v", newVouts)
+	}
+}
@@ -0,0 +1,139 @@
+package indexer
+
+import (
+	"eastnode/indexer/repository/bitcoin"
+	"eastnode/indexer/repository/db"
+	"log"
+	"os"
+	"time"
+)
+
+// polling interval of getrawmempool, also the fallback when zmq notifications are missed
+const MEMPOOL_POLL_INTERVAL = 5 * time.Second
+
+// transactions fetched per getrawtransaction batch
+const MEMPOOL_FETCH_BATCH = 500
+
+// Mempool keeps the mempool tables in sync with the bitcoind mempool
+type Mempool struct {
+	// own indexer, so prevout resolution doesn't share state with the block indexer
+	indexer     *Indexer
+	mempoolRepo bitcoin.MempoolRepositoryInterface
+
+	// txids from bitcoind zmq rawtx, nil when polling only
+	txNotifications <-chan string
+}
+
+func NewMempool(indexer *Indexer, mempoolRepo bitcoin.MempoolRepositoryInterface) *Mempool {
+	var txNotifications <-chan string
+	if os.Getenv("BTC_ZMQ_RAWTX_URL") != "" {
+		txNotifications = bitcoin.SubscribeRawTx(os.Getenv("BTC_ZMQ_RAWTX_URL"))
+	}
+
+	mempoolIndexer := &Indexer{DbRepo: indexer.DbRepo, network: indexer.network, retention: indexer.retention}
+	return &Mempool{mempoolIndexer, mempoolRepo, txNotifications}
+}
+
+func (m *Mempool) Start() {
+	for {
+		if err := m.sync(); err != nil {
+			log.Printf("Mempool sync failed: %s", err)
+		}
+
+		select {
+		case <-m.txNotifications:
+		case <-time.After(MEMPOOL_POLL_INTERVAL):
+		}
+	}
+}
+
+// sync removes the transactions that left the mempool, confirmed, replaced or evicted, and adds the new ones
+func (m *Mempool) sync() error {
+	txids, err := m.mempoolRepo.GetRawMempool()
+	if err != nil {
+		return err
+	}
+	indexed, err := m.indexer.DbRepo.GetMempoolTransactionHashes()
+	if err != nil {
+		return err
+	}
+
+	removed, added := diffMempool(indexed, txids)
+
+	if err := m.indexer.DbRepo.DeleteMempoolTransactions(removed); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(added); start += MEMPOOL_FETCH_BATCH {
+		if err := m.add(added[start:min(start+MEMPOOL_FETCH_BATCH, len(added))]); err != nil {
+			return err
+		}
+	}
+
+	if len(removed) > 0 || len(added) > 0 {
+		log.Printf("Mempool synced, %d transactions added, %d removed", len(added), len(removed))
+	}
+
+	return nil
+}
+
+func (m *Mempool) add(txids []string) error {
+	txs, err := m.mempoolRepo.GetRawTransactions(txids)
+	if err != nil {
+		return err
+	}
+
+	newTxs := []db.Transaction{}
+	newVins := []db.Vin{}
+	newVouts := []db.Vout{}
+	newRunestones := []db.Runestone{}
+
+	for _, tx := range txs {
+		// handled one by one, a transaction with an unknown prevout is skipped until the next sync
+		txTxs := []db.Transaction{}
+		txVins := []db.Vin{}
+		txVouts := []db.Vout{}
+		txRunestones := []db.Runestone{}
+		m.indexer.pendingVouts = nil
+
+		block := &bitcoin.GetBlock{Tx: []bitcoin.Tx{tx}}
+		err := m.indexer.handleTransactions(0, block, &txTxs, &txVins, &txVouts, &[]db.AddressHistory{}, &txRunestones, &[]db.Inscription{})
+		if err != nil {
+			log.Printf("Skipping mempool transaction %s: %s", tx.Txid, err)
+			continue
+		}
+
+		newTxs = append(newTxs, txTxs...)
+		newVins = append(newVins, txVins...)
+		newVouts = append(newVouts, txVouts...)
+		newRunestones = append(newRunestones, txRunestones...)
+	}
+
+	return m.indexer.DbRepo.CreateMempoolTransactions(newTxs, newVins, newVouts, newRunestones)
+}
+
+// diffMempool returns the indexed txids not in the mempool anymore and the mempool txids not indexed yet
+func diffMempool(indexed []string, mempool []string) ([]string, []string) {
+	inMempool := make(map[string]bool, len(mempool))
+	for _, txid := range mempool {
+		inMempool[txid] = true
+	}
+
+	removed := []string{}
+	isIndexed := make(map[string]bool, len(indexed))
+	for _, txid := range indexed {
+		isIndexed[txid] = true
+		if !inMempool[txid] {
+			removed = append(removed, txid)
+		}
+	}
+
+	added := []string{}
+	for _, txid := range mempool {
+		if !isIndexed[txid] {
+			added = append(added, txid)
+		}
+	}
+
+	return removed, added
+}
@@ -0,0 +1,352 @@
+package ordinals
+
+import (
+	"bytes"
+	"eastnode/indexer/repository/bitcoin"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+)
+
+// https://docs.ordinals.com/inscriptions.html
+// OP_FALSE OP_IF "ord" <tag> <value> ... OP_0 <body> ... OP_ENDIF
+const PROTOCOL_ID = "ord"
+
+const OP_FALSE = 0x00
+const OP_PUSHDATA1 = 0x4c
+const OP_PUSHDATA2 = 0x4d
+const OP_PUSHDATA4 = 0x4e
+const OP_1NEGATE = 0x4f
+const OP_1 = 0x51
+const OP_16 = 0x60
+const OP_IF = 0x63
+const OP_ENDIF = 0x68
+
+const TAG_CONTENT_TYPE = 1
+const TAG_POINTER = 2
+const TAG_PARENT = 3
+const TAG_METADATA = 5
+const TAG_METAPROTOCOL = 7
+const TAG_CONTENT_ENCODING = 9
+const TAG_DELEGATE = 11
+
+const TAPROOT_ANNEX_TAG = 0x50
+
+// Inscription is a parsed envelope, binary fields are hex encoded
+type Inscription struct {
+	Id              string   `json:"id"`
+	Input           uint32   `json:"input"`
+	Offset          uint32   `json:"offset"`
+	ContentType     string   `json:"content_type"`
+	ContentEncoding string   `json:"content_encoding"`
+	Body            string   `json:"body"`
+	Parents         []string `json:"parents"`
+	Delegate        string   `json:"delegate"`
+	Pointer         *uint64  `json:"pointer"`
+	Metaprotocol    string   `json:"metaprotocol"`
+	Metadata        string   `json:"metadata"`
+
+	// malformed envelopes are still inscriptions, the flags are kept for curse rules
+	Pushnum               bool `json:"pushnum"`
+	Stutter               bool `json:"stutter"`
+	DuplicateField        bool `json:"duplicate_field"`
+	IncompleteField       bool `json:"incomplete_field"`
+	UnrecognizedEvenField bool `json:"unrecognized_even_field"`
+}
+
+type instruction struct {
+	op   byte
+	push bool
+	data []byte
+}
+
+type envelope struct {
+	input   uint32
+	offset  uint32
+	payload [][]byte
+	pushnum bool
+	stutter bool
+}
+
+// ParseInscriptions returns the inscriptions of every input's tapscript, ids are numbered in input order
+func ParseInscriptions(tx *bitcoin.Tx) []Inscription {
+	envelopes := []envelope{}
+	for i, vin := range tx.Vin {
+		tapscript := tapscript(vin.Txinwitness)
+		if tapscript == nil {
+			continue
+		}
+
+		inputEnvelopes, err := parseEnvelopes(tapscript, uint32(i))
+		if err != nil {
+			continue
+		}
+		envelopes = append(envelopes, inputEnvelopes...)
+	}
+
+	inscriptions := make([]Inscription, 0, len(envelopes))
+	for i, envelope := range envelopes {
+		inscription := envelope.inscription()
+		inscription.Id = fmt.Sprintf("%si%d", tx.Txid, i)
+		inscriptions = append(inscriptions, inscription)
+	}
+
+	return inscriptions
+}
+
+// tapscript is the second to last witness item, or third to last with an annex
+func tapscript(witness []string) []byte {
+	position := 2
+	if len(witness) >= 2 && len(witness[len(witness)-1]) >= 2 && witness[len(witness)-1][:2] == fmt.Sprintf("%02x", TAPROOT_ANNEX_TAG) {
+		position = 3
+	}
+	if len(witness) < position {
+		return nil
+	}
+
+	script, err := hex.DecodeString(witness[len(witness)-position])
+	if err != nil {
+		return nil
+	}
+
+	return script
+}
+
+func parseEnvelopes(script []byte, input uint32) ([]envelope, error) {
+	instructions, err := parseScript(script)
+	if err != nil {
+		return nil, err
+	}
+
+	envelopes := []envelope{}
+	stuttered := false
+	for i := 0; i < len(instructions); {
+		current := instructions[i]
+		i++
+		if !isEmptyPush(current) {
+			continue
+		}
+
+		stutter, envelope, next := parseEnvelope(instructions, i)
+		i = next
+		if envelope != nil {
+			envelope.input = input
+			envelope.offset = uint32(len(envelopes))
+			envelope.stutter = stuttered
+			envelopes = append(envelopes, *envelope)
+		} else {
+			stuttered = stutter
+		}
+	}
+
+	return envelopes, nil
+}
+
+// parseEnvelope parses the instructions after an OP_FALSE, it returns the index of the next unparsed instruction
+func parseEnvelope(instructions []instruction, i int) (bool, *envelope, int) {
+	// an OP_FALSE right after OP_FALSE may start the envelope
+	stutter := func(i int) bool {
+		return i < len(instructions) && isEmptyPush(instructions[i])
+	}
+
+	if i >= len(instructions) || instructions[i].push || instructions[i].op != OP_IF {
+		return stutter(i), nil, i
+	}
+	i++
+
+	if i >= len(instructions) || !instructions[i].push || string(instructions[i].data) != PROTOCOL_ID {
+		return stutter(i), nil, i
+	}
+	i++
+
+	envelope := &envelope{payload: [][]byte{}}
+	for ; i < len(instructions); i++ {
+		current := instructions[i]
+
+		switch {
+		case current.push:
+			envelope.payload = append(envelope.payload, current.data)
+		case current.op == OP_ENDIF:
+			return false, envelope, i + 1
+		case current.op == OP_1NEGATE:
+			envelope.pushnum = true
+			envelope.payload = append(envelope.payload, []byte{0x81})
+		case current.op >= OP_1 && current.op <= OP_16:
+			envelope.pushnum = true
+			envelope.payload = append(envelope.payload, []byte{current.op - OP_1 + 1})
+		default:
+			return false, nil, i + 1
+		}
+	}
+
+	return false, nil, i
+}
+
+func (e *envelope) inscription() Inscription {
+	inscription := Inscription{Input: e.input, Offset: e.offset, Parents: []string{}, Pushnum: e.pushnum, Stutter: e.stutter}
+
+	// the body starts after the first empty push at a tag position
+	body := -1
+	for i := 0; i < len(e.payload); i += 2 {
+		if len(e.payload[i]) == 0 {
+			body = i
+			break
+		}
+	}
+
+	fieldsEnd := len(e.payload)
+	if body >= 0 {
+		fieldsEnd = body
+	}
+
+	keys := []string{}
+	fields := map[string][][]byte{}
+	for i := 0; i < fieldsEnd; i += 2 {
+		if i+1 >= fieldsEnd {
+			inscription.IncompleteField = true
+			break
+		}
+
+		key := string(e.payload[i])
+		if _, ok := fields[key]; !ok {
+			keys = append(keys, key)
+		} else {
+			inscription.DuplicateField = true
+		}
+		fields[key] = append(fields[key], e.payload[i+1])
+	}
+
+	take := func(tag byte) ([]byte, bool) {
+		key := string([]byte{tag})
+		values, ok := fields[key]
+		if !ok {
+			return nil, false
+		}
+
+		if len(values) == 1 {
+			delete(fields, key)
+		} else {
+			fields[key] = values[1:]
+		}
+		return values[0], true
+	}
+
+	if value, ok := take(TAG_CONTENT_ENCODING); ok {
+		inscription.ContentEncoding = string(value)
+	}
+	if value, ok := take(TAG_CONTENT_TYPE); ok {
+		inscription.ContentType = string(value)
+	}
+	if value, ok := take(TAG_DELEGATE); ok {
+		inscription.Delegate = inscriptionId(value)
+	}
+	if values, ok := fields[string([]byte{TAG_METADATA})]; ok {
+		// metadata is chunked, its pushes are concatenated
+		delete(fields, string([]byte{TAG_METADATA}))
+		inscription.Metadata = hex.EncodeToString(bytes.Join(values, nil))
+	}
+	if value, ok := take(TAG_METAPROTOCOL); ok {
+		inscription.Metaprotocol = string(value)
+	}
+	if values, ok := fields[string([]byte{TAG_PARENT})]; ok {
+		delete(fields, string([]byte{TAG_PARENT}))
+		for _, value := range values {
+			if parent := inscriptionId(value); parent != "" {
+				inscription.Parents = append(inscription.Parents, parent)
+			}
+		}
+	}
+	if value, ok := take(TAG_POINTER); ok {
+		inscription.Pointer = pointer(value)
+	}
+
+	for _, key := range keys {
+		if _, ok := fields[key]; ok && len(key) > 0 && key[0]%2 == 0 {
+			inscription.UnrecognizedEvenField = true
+		}
+	}
+
+	if body >= 0 {
+		inscription.Body = hex.EncodeToString(bytes.Join(e.payload[body+1:], nil))
+	}
+
+	return inscription
+}
+
+// inscriptionId decodes a parent or delegate, the txid in internal byte order followed by the minimal little endian index
+func inscriptionId(value []byte) string {
+	if len(value) < 32 || len(value) > 36 {
+		return ""
+	}
+
+	index := value[32:]
+	if len(index) > 0 && index[len(index)-1] == 0 {
+		return ""
+	}
+
+	padded := make([]byte, 4)
+	copy(padded, index)
+
+	txid := make([]byte, 32)
+	for i := range txid {
+		txid[i] = value[31-i]
+	}
+
+	return fmt.Sprintf("%si%d", hex.EncodeToString(txid), binary.LittleEndian.Uint32(padded))
+}
+
+// pointer is a little endian u64, ignored if it doesn't fit
+func pointer(value []byte) *uint64 {
+	for i := 8; i < len(value); i++ {
+		if value[i] != 0 {
+			return nil
+		}
+	}
+
+	padded := make([]byte, 8)
+	copy(padded, value)
+	p := binary.LittleEndian.Uint64(padded)
+	return &p
+}
+
+func isEmptyPush(i instruction) bool {
+	return i.push && len(i.data) == 0
+}
+
+// parseScript splits a script into push and opcode instructions
+func parseScript(script []byte) ([]instruction, error) {
+	instructions := []instruction{}
+
+	for i := 0; i < len(script); {
+		op := script[i]
+		i++
+
+		var size uint64
+		switch {
+		case op <= 0x4b:
+			size = uint64(op)
+		case op == OP_PUSHDATA1 && i+1 <= len(script):
+			size = uint64(script[i])
+			i += 1
+		case op == OP_PUSHDATA2 && i+2 <= len(script):
+			size = uint64(binary.LittleEndian.Uint16(script[i:]))
+			i += 2
+		case op == OP_PUSHDATA4 && i+4 <= len(script):
+			size = uint64(binary.LittleEndian.Uint32(script[i:]))
+			i += 4
+		case op >= OP_PUSHDATA1 && op <= OP_PUSHDATA4:
+			return nil, fmt.Errorf("truncated push size at %d", i)
+		default:
+			instructions = append(instructions, instruction{op: op})
+			continue
+		}
+
+		if uint64(len(script)-i) < size {
+			return nil, fmt.Errorf("truncated push at %d", i)
+		}
+		instructions = append(instructions, instruction{op: op, push: true, data: script[i : i+int(size)]})
+		i += int(size)
+	}
+
+	return instructions, nil
+}
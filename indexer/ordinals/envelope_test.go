@@ -0,0 +1,121 @@
+package ordinals
+
+import (
+	"eastnode/indexer/repository/bitcoin"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func push(data []byte) []byte {
+	if len(data) == 0 {
+		return []byte{OP_FALSE}
+	}
+	return append([]byte{byte(len(data))}, data...)
+}
+
+// envelopeScript wraps the pushes in an envelope, followed by a checksig like real inscriptions
+func envelopeScript(pushes ...[]byte) []byte {
+	script := append([]byte{0x20}, make([]byte, 32)...)
+	script = append(script, 0xac, OP_FALSE, OP_IF)
+	script = append(script, push([]byte(PROTOCOL_ID))...)
+	for _, p := range pushes {
+		script = append(script, push(p)...)
+	}
+	return append(script, OP_ENDIF)
+}
+
+func inscriptionTx(scripts ...[]byte) *bitcoin.Tx {
+	tx := &bitcoin.Tx{Txid: strings.Repeat("ab", 32)}
+	for _, script := range scripts {
+		tx.Vin = append(tx.Vin, bitcoin.Vin{Txinwitness: []string{strings.Repeat("cc", 64), hex.EncodeToString(script), "c1" + strings.Repeat("dd", 32)}})
+	}
+	return tx
+}
+
+func TestParseInscriptions(t *testing.T) {
+	parent := append(make([]byte, 31), 0x01, 0x02)
+	script := envelopeScript(
+		[]byte{TAG_CONTENT_TYPE}, []byte("text/plain;charset=utf-8"),
+		[]byte{TAG_PARENT}, parent,
+		[]byte{TAG_POINTER}, []byte{0x10, 0x27},
+		[]byte{TAG_METAPROTOCOL}, []byte("brc-20"),
+		[]byte{TAG_METADATA}, []byte{0xa1, 0x01},
+		[]byte{}, []byte("Hello, "), []byte("world!"),
+	)
+
+	inscriptions := ParseInscriptions(inscriptionTx([]byte{0x51}, script))
+	if len(inscriptions) != 1 {
+		t.Fatalf("Expected 1 inscription, got %d", len(inscriptions))
+	}
+
+	inscription := inscriptions[0]
+	if inscription.Id != strings.Repeat("ab", 32)+"i0" || inscription.Input != 1 || inscription.Offset != 0 {
+		t.Errorf("Inscription id incorrect %s %d %d", inscription.Id, inscription.Input, inscription.Offset)
+	}
+
+	if inscription.ContentType != "text/plain;charset=utf-8" || inscription.Body != hex.EncodeToString([]byte("Hello, world!")) || inscription.Metaprotocol != "brc-20" || inscription.Metadata != "a101" {
+		t.Errorf("Inscription fields incorrect %+v", inscription)
+	}
+
+	if len(inscription.Parents) != 1 || inscription.Parents[0] != "0100"+strings.Repeat("00", 30)+"i2" {
+		t.Errorf("Parents incorrect %v", inscription.Parents)
+	}
+
+	if inscription.Pointer == nil || *inscription.Pointer != 10000 {
+		t.Errorf("Pointer incorrect %v", inscription.Pointer)
+	}
+
+	if inscription.Pushnum || inscription.Stutter || inscription.DuplicateField || inscription.IncompleteField || inscription.UnrecognizedEvenField {
+		t.Errorf("Inscription should not be flagged %+v", inscription)
+	}
+}
+
+func TestParseInscriptions_Flags(t *testing.T) {
+	// two envelopes in the first input and one in the second, numbered in order
+	first := envelopeScript([]byte{TAG_CONTENT_TYPE}, []byte("a"), []byte{TAG_CONTENT_TYPE}, []byte("b"))
+	second := envelopeScript([]byte{TAG_POINTER}, []byte{1}, []byte{TAG_POINTER}, []byte{2}, []byte{4})
+	first = append(first, second[34:]...)
+	// OP_FALSE OP_FALSE OP_IF "ord" OP_1 OP_ENDIF
+	stuttered := []byte{OP_FALSE, OP_FALSE, OP_IF, 0x03, 'o', 'r', 'd', OP_1, OP_ENDIF}
+
+	inscriptions := ParseInscriptions(inscriptionTx(first, stuttered))
+	if len(inscriptions) != 3 {
+		t.Fatalf("Expected 3 inscriptions, got %d", len(inscriptions))
+	}
+
+	if inscriptions[0].ContentType != "a" || !inscriptions[0].DuplicateField || inscriptions[0].UnrecognizedEvenField {
+		t.Errorf("Duplicate field incorrect %+v", inscriptions[0])
+	}
+
+	if inscriptions[1].Offset != 1 || *inscriptions[1].Pointer != 1 || !inscriptions[1].IncompleteField || !inscriptions[1].UnrecognizedEvenField {
+		t.Errorf("Incomplete field incorrect %+v", inscriptions[1])
+	}
+
+	if inscriptions[2].Id != strings.Repeat("ab", 32)+"i2" || !inscriptions[2].Stutter || !inscriptions[2].Pushnum {
+		t.Errorf("Stutter incorrect %+v", inscriptions[2])
+	}
+}
+
+func TestParseInscriptions_NoEnvelope(t *testing.T) {
+	tests := [][]byte{
+		// OP_FALSE OP_IF "bar" OP_ENDIF
+		{OP_FALSE, OP_IF, 0x03, 'b', 'a', 'r', OP_ENDIF},
+		// no OP_ENDIF
+		{OP_FALSE, OP_IF, 0x03, 'o', 'r', 'd', OP_FALSE},
+		// truncated push
+		{OP_FALSE, OP_IF, 0x03, 'o', 'r', 'd', OP_ENDIF, 0x05, 0x01},
+	}
+
+	for _, script := range tests {
+		if inscriptions := ParseInscriptions(inscriptionTx(script)); len(inscriptions) != 0 {
+			t.Errorf("Expected no inscription in %x, got %+v", script, inscriptions)
+		}
+	}
+
+	// key path spends have no tapscript
+	tx := &bitcoin.Tx{Vin: []bitcoin.Vin{{Txinwitness: []string{strings.Repeat("cc", 64)}}}}
+	if inscriptions := ParseInscriptions(tx); len(inscriptions) != 0 {
+		t.Errorf("Expected no inscription, got %+v", inscriptions)
+	}
+}
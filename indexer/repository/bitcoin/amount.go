@@ -0,0 +1,46 @@
+package bitcoin
+
+import (
+	"fmt"
+	"math/big"
+)
+
+const SATS_PER_BTC = 100_000_000
+
+// Amount is a value in sats, decoded exactly from the BTC decimal of the RPC json
+type Amount int64
+
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	btc, ok := new(big.Rat).SetString(string(data))
+	if !ok {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+
+	sats := btc.Mul(btc, big.NewRat(SATS_PER_BTC, 1))
+	if !sats.IsInt() || !sats.Num().IsInt64() {
+		return fmt.Errorf("invalid amount %s", data)
+	}
+
+	*a = Amount(sats.Num().Int64())
+	return nil
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// String formats the amount in BTC with 8 decimals
+func (a Amount) String() string {
+	sats := int64(a)
+	sign := ""
+	if sats < 0 {
+		sign = "-"
+		sats = -sats
+	}
+
+	return fmt.Sprintf("%s%d.%08d", sign, sats/SATS_PER_BTC, sats%SATS_PER_BTC)
+}
@@ -0,0 +1,50 @@
+package bitcoin
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAmount_UnmarshalJSON(t *testing.T) {
+	// values where int64(value * 100_000_000) is off by one sat
+	amounts := map[string]Amount{
+		"0.29":              29_000_000,
+		"0.57":              57_000_000,
+		"1.15":              115_000_000,
+		"4.35":              435_000_000,
+		"0.00000001":        1,
+		"1e-08":             1,
+		"20999999.97690000": 2_099_999_997_690_000,
+		"50.00000000":       5_000_000_000,
+		"0":                 0,
+	}
+
+	for value, expected := range amounts {
+		vout := Vout{}
+		if err := json.Unmarshal([]byte(`{"value":`+value+`}`), &vout); err != nil {
+			t.Errorf("Failed to unmarshal %s: %s", value, err)
+			continue
+		}
+
+		if vout.Value != expected {
+			t.Errorf("Amount %s incorrect, expected %d got %d", value, expected, vout.Value)
+		}
+	}
+
+	for _, value := range []string{"0.000000001", `"1"`} {
+		vout := Vout{}
+		if err := json.Unmarshal([]byte(`{"value":`+value+`}`), &vout); err == nil {
+			t.Errorf("Amount %s should be invalid", value)
+		}
+	}
+}
+
+func TestAmount_String(t *testing.T) {
+	if s := Amount(2_099_999_997_690_000).String(); s != "20999999.97690000" {
+		t.Errorf("Amount string incorrect %s", s)
+	}
+
+	if s := Amount(-1).String(); s != "-0.00000001" {
+		t.Errorf("Amount string incorrect %s", s)
+	}
+}
@@ -3,6 +3,9 @@ package bitcoin
 import (
 	"bytes"
 	"log"
+	"os"
+	"strings"
+	"time"
 
 	"encoding/base64"
 	"encoding/json"
@@ -11,6 +14,21 @@ import (
 	"net/http"
 )
 
+const RPC_MAX_ATTEMPTS = 5
+const RPC_RETRY_DELAY = 200 * time.Millisecond
+const RPC_TIMEOUT = 60 * time.Second
+
+// shared by every repository so connections are kept alive and reused
+var httpClient = &http.Client{
+	Timeout: RPC_TIMEOUT,
+	Transport: &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        64,
+		MaxIdleConnsPerHost: 64,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
 type BitcoinRepository struct {
 	url      string
 	username string
@@ -21,23 +39,58 @@ func NewBitcoinRepo(url, username, password string) *BitcoinRepository {
 	return &BitcoinRepository{url, username, password}
 }
 
+// NewBitcoinRepoFromEnv uses BTC_RPC_URL with BTC_RPC_USER and BTC_RPC_PASSWORD,
+// or the bitcoind cookie file in BTC_RPC_COOKIE_FILE
+func NewBitcoinRepoFromEnv() *BitcoinRepository {
+	username := os.Getenv("BTC_RPC_USER")
+	password := os.Getenv("BTC_RPC_PASSWORD")
+
+	if os.Getenv("BTC_RPC_COOKIE_FILE") != "" {
+		cookie, err := os.ReadFile(os.Getenv("BTC_RPC_COOKIE_FILE"))
+		if err != nil {
+			panic(err)
+		}
+
+		username, password, _ = strings.Cut(strings.TrimSpace(string(cookie)), ":")
+	}
+
+	return NewBitcoinRepo(os.Getenv("BTC_RPC_URL"), username, password)
+}
+
 func (b *BitcoinRepository) authorization() string {
 	str := b.username + ":" + b.password
 	encoded := base64.StdEncoding.EncodeToString([]byte(str))
 	return "Basic " + encoded
 }
 
-func (b *BitcoinRepository) rpc(method string, params []json.RawMessage) ([]byte, error) {
-	request := &Request{
-		Jsonrpc: "1.0",
-		Method:  method,
-		Params:  params,
+// post sends a request body, retrying with exponential backoff on connection errors and 5xx responses,
+// bitcoind answers 503 when its work queue depth is exceeded
+func (b *BitcoinRepository) post(body []byte) ([]byte, error) {
+	var err error
+	delay := RPC_RETRY_DELAY
+
+	for attempt := 1; attempt <= RPC_MAX_ATTEMPTS; attempt++ {
+		if attempt > 1 {
+			log.Printf("bitcoin rpc failed (attempt %d): %s", attempt-1, err)
+			time.Sleep(delay)
+			delay *= 2
+		}
+
+		var resBytes []byte
+		var retry bool
+		resBytes, retry, err = b.postOnce(body)
+		if err == nil || !retry {
+			return resBytes, err
+		}
 	}
-	requestMarshalled, _ := json.Marshal(request)
 
-	r, err := http.NewRequest("POST", b.url, bytes.NewBuffer(requestMarshalled))
+	return nil, err
+}
+
+func (b *BitcoinRepository) postOnce(body []byte) ([]byte, bool, error) {
+	r, err := http.NewRequest("POST", b.url, bytes.NewBuffer(body))
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 
 	if b.username != "" && b.password != "" {
@@ -45,86 +98,170 @@ func (b *BitcoinRepository) rpc(method string, params []json.RawMessage) ([]byte
 	}
 	r.Header.Add("Content-Type", "application/json")
 
-	c := &http.Client{}
-	res, err := c.Do(r)
+	res, err := httpClient.Do(r)
 	if err != nil {
-		return nil, err
+		return nil, true, err
 	}
+	defer res.Body.Close()
 
-	if res.StatusCode != 200 {
-		body, _ := io.ReadAll(res.Body)
-		log.Println(string(body))
-		return nil, fmt.Errorf("errors.request status code: %d", res.StatusCode)
+	resBytes, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, true, err
 	}
 
-	defer res.Body.Close()
+	if res.StatusCode == http.StatusUnauthorized {
+		return nil, false, ErrUnauthorized
+	}
+
+	// rpc errors come with status 404 or 500 and a json body with the error
+	if res.StatusCode != 200 && !json.Valid(resBytes) {
+		log.Println(string(resBytes))
+		err := fmt.Errorf("errors.request status code: %d", res.StatusCode)
+
+		retry := res.StatusCode >= 500 || strings.Contains(string(resBytes), "Work queue depth exceeded")
+		return nil, retry, err
+	}
+
+	return resBytes, false, nil
+}
+
+// rpc returns the result of method, or an *RPCError when bitcoind returns an error
+func (b *BitcoinRepository) rpc(method string, params []json.RawMessage) (json.RawMessage, error) {
+	request := &Request{
+		Jsonrpc: "1.0",
+		Method:  method,
+		Params:  params,
+	}
+	requestMarshalled, _ := json.Marshal(request)
 
-	body, err := io.ReadAll(res.Body)
+	resBytes, err := b.post(requestMarshalled)
 	if err != nil {
 		return nil, err
 	}
 
-	return body, nil
+	response := Response{}
+	if err := json.Unmarshal(resBytes, &response); err != nil {
+		return nil, fmt.Errorf("%s: failed to unmarshal response: %w", method, err)
+	}
+
+	if response.Error != nil {
+		return nil, fmt.Errorf("%s: %w", method, response.Error)
+	}
+
+	return response.Result, nil
 }
 
-func (b *BitcoinRepository) GetBlockHash(height int32) (string, error) {
-	params, _ := json.Marshal(height)
-	paramsJson := []json.RawMessage{json.RawMessage(params)}
-	resBytes, err := b.rpc("getblockhash", paramsJson)
+// call unmarshals the result of method into result
+func (b *BitcoinRepository) call(method string, result interface{}, params ...interface{}) error {
+	paramsJson := make([]json.RawMessage, len(params))
+	for i, param := range params {
+		jsonParam, err := json.Marshal(param)
+		if err != nil {
+			return fmt.Errorf("failed to marshal parameter: %w", err)
+		}
+		paramsJson[i] = jsonParam
+	}
+
+	resultJson, err := b.rpc(method, paramsJson)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	getBlockHash := GetBlockHashRPC{}
-	_ = json.Unmarshal(resBytes, &getBlockHash)
+	if err := json.Unmarshal(resultJson, result); err != nil {
+		return fmt.Errorf("%s: failed to unmarshal result: %w", method, err)
+	}
 
-	return getBlockHash.Result, nil
+	return nil
 }
 
-func (b *BitcoinRepository) GetBlock(blockHash string) (*GetBlock, error) {
-	blockHashParam, _ := json.Marshal(blockHash)
-	verbosity, _ := json.Marshal(3)
-	paramsJson := []json.RawMessage{json.RawMessage(blockHashParam), json.RawMessage(verbosity)}
+// BatchRPC sends the requests as a single JSON-RPC batch, responses are returned in request order
+func (b *BitcoinRepository) BatchRPC(requests []Request) ([]Response, error) {
+	for i := range requests {
+		requests[i].Jsonrpc = "1.0"
+		requests[i].Id = i
+	}
+	requestsMarshalled, _ := json.Marshal(requests)
 
-	resBytes, err := b.rpc("getblock", paramsJson)
+	resBytes, err := b.post(requestsMarshalled)
 	if err != nil {
 		return nil, err
 	}
 
-	getBlock := GetBlockRPC{}
-	_ = json.Unmarshal(resBytes, &getBlock)
+	batch := []Response{}
+	if err := json.Unmarshal(resBytes, &batch); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch response: %w", err)
+	}
+
+	responses := make([]Response, len(requests))
+	for _, response := range batch {
+		if response.Id < 0 || response.Id >= len(requests) {
+			return nil, fmt.Errorf("unexpected batch response id %d", response.Id)
+		}
+		responses[response.Id] = response
+	}
 
-	return &getBlock.Result, nil
+	return responses, nil
 }
 
-func (b *BitcoinRepository) GetBlockWithVerbosity(blockHash string, verbosity int32) (*GetBlock, error) {
-	blockHashParam, _ := json.Marshal(blockHash)
-	verbosityParam, _ := json.Marshal(verbosity)
-	paramsJson := []json.RawMessage{json.RawMessage(blockHashParam), json.RawMessage(verbosityParam)}
+func (b *BitcoinRepository) GetBlockHash(height int32) (string, error) {
+	blockHash := ""
+	err := b.call("getblockhash", &blockHash, height)
+
+	return blockHash, err
+}
 
-	resBytes, err := b.rpc("getblock", paramsJson)
+// GetBlockHashes gets the hashes of many heights in one batch request
+func (b *BitcoinRepository) GetBlockHashes(heights []int32) ([]string, error) {
+	requests := make([]Request, len(heights))
+	for i, height := range heights {
+		params, _ := json.Marshal(height)
+		requests[i] = Request{Method: "getblockhash", Params: []json.RawMessage{params}}
+	}
+
+	responses, err := b.BatchRPC(requests)
 	if err != nil {
 		return nil, err
 	}
 
-	getBlock := GetBlockRPC{}
-	_ = json.Unmarshal(resBytes, &getBlock)
+	hashes := make([]string, len(heights))
+	for i, response := range responses {
+		if response.Error != nil {
+			return nil, fmt.Errorf("getblockhash %d: %w", heights[i], response.Error)
+		}
+
+		if err := json.Unmarshal(response.Result, &hashes[i]); err != nil {
+			return nil, fmt.Errorf("getblockhash %d: failed to unmarshal result: %w", heights[i], err)
+		}
+	}
 
-	return &getBlock.Result, nil
+	return hashes, nil
 }
 
-func (b *BitcoinRepository) GetBlockCount() (int32, error) {
-	paramsJson := []json.RawMessage{}
+func (b *BitcoinRepository) GetBlock(blockHash string) (*GetBlock, error) {
+	return b.GetBlockWithVerbosity(blockHash, 3)
+}
 
-	resBytes, err := b.rpc("getblockcount", paramsJson)
-	if err != nil {
-		return 0, err
+func (b *BitcoinRepository) GetBlockWithVerbosity(blockHash string, verbosity int32) (*GetBlock, error) {
+	block := &GetBlock{}
+	if err := b.call("getblock", block, blockHash, verbosity); err != nil {
+		return nil, err
 	}
 
-	getBlockCount := GetBlockCountRPC{}
-	_ = json.Unmarshal(resBytes, &getBlockCount)
+	return block, nil
+}
+
+func (b *BitcoinRepository) GetBlockCount() (int32, error) {
+	blockCount := int32(0)
+	err := b.call("getblockcount", &blockCount)
+
+	return blockCount, err
+}
+
+func (b *BitcoinRepository) GetBestBlockHash() (string, error) {
+	blockHash := ""
+	err := b.call("getbestblockhash", &blockHash)
 
-	return getBlockCount.Result, nil
+	return blockHash, err
 }
 
 func (b *BitcoinRepository) ForwardRPC(method string, params []interface{}) (json.RawMessage, error) {
@@ -139,27 +276,49 @@ func (b *BitcoinRepository) ForwardRPC(method string, params []interface{}) (jso
 	}
 
 	// Call the existing rpc method
-	resBytes, err := b.rpc(method, paramsJson)
+	return b.rpc(method, paramsJson)
+}
+
+// GetRawMempool returns the txids of the mempool transactions
+func (b *BitcoinRepository) GetRawMempool() ([]string, error) {
+	txids := []string{}
+	err := b.call("getrawmempool", &txids)
+
+	return txids, err
+}
+
+// GetRawTransactions gets many transactions with their prevouts in one batch request,
+// transactions that left the mempool meanwhile are skipped
+func (b *BitcoinRepository) GetRawTransactions(txids []string) ([]Tx, error) {
+	requests := make([]Request, len(txids))
+	for i, txid := range txids {
+		txidParam, _ := json.Marshal(txid)
+		// verbosity 2 includes the prevouts since bitcoind 25
+		verbosityParam, _ := json.Marshal(2)
+		requests[i] = Request{Method: "getrawtransaction", Params: []json.RawMessage{txidParam, verbosityParam}}
+	}
+
+	responses, err := b.BatchRPC(requests)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse the response
-	var response struct {
-		Result json.RawMessage `json:"result"`
-		Error  *struct {
-			Code    int    `json:"code"`
-			Message string `json:"message"`
-		} `json:"error"`
-	}
-	if err := json.Unmarshal(resBytes, &response); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %v", err)
-	}
+	txs := make([]Tx, 0, len(txids))
+	for i, response := range responses {
+		if response.Error != nil {
+			if response.Error.Code == RPC_INVALID_ADDRESS_OR_KEY {
+				continue
+			}
 
-	// Check for RPC error
-	if response.Error != nil {
-		return nil, fmt.Errorf("RPC error (code %d): %s", response.Error.Code, response.Error.Message)
+			return nil, fmt.Errorf("getrawtransaction %s: %w", txids[i], response.Error)
+		}
+
+		tx := Tx{}
+		if err := json.Unmarshal(response.Result, &tx); err != nil {
+			return nil, fmt.Errorf("getrawtransaction %s: failed to unmarshal result: %w", txids[i], err)
+		}
+		txs = append(txs, tx)
 	}
 
-	return response.Result, nil
+	return txs, nil
 }
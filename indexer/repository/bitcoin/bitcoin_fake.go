@@ -1,7 +1,6 @@
 package bitcoin
 
 import (
-	"errors"
 	"fmt"
 	"math/rand"
 	"time"
@@ -26,21 +25,21 @@ func (m *MockBitcoinRepo) GetBlockHash(height int32) (string, error) {
 	if hash, ok := m.blocks[height]; ok {
 		return hash, nil
 	}
-	return "", errors.New("block not found")
+	return "", &RPCError{Code: RPC_INVALID_PARAMETER, Message: "Block height out of range"}
 }
 
 func (m *MockBitcoinRepo) GetBlock(blockHash string) (*GetBlock, error) {
 	if block, ok := m.blockDetails[blockHash]; ok {
 		return block, nil
 	}
-	return nil, errors.New("block not found")
+	return nil, &RPCError{Code: RPC_INVALID_ADDRESS_OR_KEY, Message: "Block not found"}
 }
 
 func (m *MockBitcoinRepo) GetBlockWithVerbosity(blockHash string, verbosity int32) (*GetBlock, error) {
 	if block, ok := m.blockDetails[blockHash]; ok {
 		return block, nil
 	}
-	return nil, errors.New("block not found")
+	return nil, &RPCError{Code: RPC_INVALID_ADDRESS_OR_KEY, Message: "Block not found"}
 }
 
 func (m *MockBitcoinRepo) GetBlockCount() (int32, error) {
@@ -94,95 +93,13 @@ func (m *MockBitcoinRepo) AddOrReplaceBlock(height int32) GetBlock {
 		Weight:            rand.Intn(4000000) + 3000000,
 		Previousblockhash: previousblockhash,
 		Nextblockhash:     nextblockhash,
-		Tx: []struct {
-			Txid     string `json:"txid"`
-			Hash     string `json:"hash"`
-			Version  int    `json:"version"`
-			Size     int    `json:"size"`
-			Vsize    int    `json:"vsize"`
-			Weight   int    `json:"weight"`
-			Locktime int    `json:"locktime"`
-			Vin      []struct {
-				Coinbase    string   `json:"coinbase"`
-				Txid        string   `json:"txid"`
-				Vout        int64    `json:"vout"`
-				Txinwitness []string `json:"txinwitness"`
-				Sequence    int64    `json:"sequence"`
-				ScriptSig   struct {
-					Asm string `json:"asm"`
-					Hex string `json:"hex"`
-				} `json:"scriptSig"`
-				PrevOutput struct {
-					Value        float64 `json:"value"`
-					ScriptPubKey struct {
-						Asm     string `json:"asm"`
-						Desc    string `json:"desc"`
-						Hex     string `json:"hex"`
-						Address string `json:"address"`
-						Type    string `json:"type"`
-					} `json:"scriptPubKey"`
-				} `json:"prevout"`
-			} `json:"vin"`
-			Vout []struct {
-				Value        float64 `json:"value"`
-				N            int     `json:"n"`
-				ScriptPubKey struct {
-					Asm     string `json:"asm"`
-					Desc    string `json:"desc"`
-					Hex     string `json:"hex"`
-					Address string `json:"address"`
-					Type    string `json:"type"`
-				} `json:"scriptPubKey"`
-			} `json:"vout"`
-			Hex string `json:"hex"`
-		}{},
+		Tx:                []Tx{},
 	}
 
 	// Generate random transactions
 	numTx := rand.Intn(100) + 1
 	for i := 0; i < numTx; i++ {
-		tx := struct {
-			Txid     string `json:"txid"`
-			Hash     string `json:"hash"`
-			Version  int    `json:"version"`
-			Size     int    `json:"size"`
-			Vsize    int    `json:"vsize"`
-			Weight   int    `json:"weight"`
-			Locktime int    `json:"locktime"`
-			Vin      []struct {
-				Coinbase    string   `json:"coinbase"`
-				Txid        string   `json:"txid"`
-				Vout        int64    `json:"vout"`
-				Txinwitness []string `json:"txinwitness"`
-				Sequence    int64    `json:"sequence"`
-				ScriptSig   struct {
-					Asm string `json:"asm"`
-					Hex string `json:"hex"`
-				} `json:"scriptSig"`
-				PrevOutput struct {
-					Value        float64 `json:"value"`
-					ScriptPubKey struct {
-						Asm     string `json:"asm"`
-						Desc    string `json:"desc"`
-						Hex     string `json:"hex"`
-						Address string `json:"address"`
-						Type    string `json:"type"`
-					} `json:"scriptPubKey"`
-				} `json:"prevout"`
-			} `json:"vin"`
-			Vout []struct {
-				Value        float64 `json:"value"`
-				N            int     `json:"n"`
-				ScriptPubKey struct {
-					Asm     string `json:"asm"`
-					Desc    string `json:"desc"`
-					Hex     string `json:"hex"`
-					Address string `json:"address"`
-					Type    string `json:"type"`
-				} `json:"scriptPubKey"`
-			} `json:"vout"`
-			Hex string `json:"hex"`
-		}{
+		tx := Tx{
 			Txid:     fmt.Sprintf("tx_%d_%d", height, i),
 			Hash:     fmt.Sprintf("txhash_%d_%d", height, i),
 			Version:  2,
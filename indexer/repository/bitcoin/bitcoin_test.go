@@ -0,0 +1,70 @@
+package bitcoin
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetBlockHashes_Retry(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			http.Error(w, "Work queue depth exceeded", http.StatusServiceUnavailable)
+			return
+		}
+
+		requests := []Request{}
+		json.NewDecoder(r.Body).Decode(&requests)
+
+		// answer in reverse order, responses are matched by id
+		responses := []Response{}
+		for i := len(requests) - 1; i >= 0; i-- {
+			height := 0
+			json.Unmarshal(requests[i].Params[0], &height)
+			result, _ := json.Marshal(fmt.Sprintf("hash_%d", height))
+			responses = append(responses, Response{Id: requests[i].Id, Result: result})
+		}
+		json.NewEncoder(w).Encode(responses)
+	}))
+	defer server.Close()
+
+	repo := NewBitcoinRepo(server.URL, "", "")
+	hashes, err := repo.GetBlockHashes([]int32{5, 6, 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calls != 2 {
+		t.Errorf("Expected a retry, got %d calls", calls)
+	}
+
+	for i, hash := range hashes {
+		if hash != fmt.Sprintf("hash_%d", i+5) {
+			t.Errorf("Block hash %d incorrect %s", i, hash)
+		}
+	}
+}
+
+func TestGetBlockHash_RPCError(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"result":null,"error":{"code":-8,"message":"Block height out of range"},"id":0}`))
+	}))
+	defer server.Close()
+
+	repo := NewBitcoinRepo(server.URL, "", "")
+	hash, err := repo.GetBlockHash(100)
+	if !IsBlockNotFound(err) {
+		t.Errorf("Expected block not found, got %q %v", hash, err)
+	}
+
+	if calls != 1 {
+		t.Errorf("RPC errors should not be retried, got %d calls", calls)
+	}
+}
@@ -0,0 +1,201 @@
+package bitcoin
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// network magic prefixing every block record in the blk files
+var blockFileMagics = map[uint32]string{
+	0xd9b4bef9: "mainnet",
+	0x0709110b: "testnet3",
+	0x283f161c: "testnet4",
+	0x40cf030a: "signet",
+	0xdab5bffa: "regtest",
+}
+
+type blockLocation struct {
+	file     int
+	offset   int64
+	size     uint32
+	prevHash string
+}
+
+// BlockFileRepository reads blocks straight from the blk*.dat files of a bitcoind blocks directory,
+// the vin prevouts are not part of raw blocks and have to be resolved by the indexer
+type BlockFileRepository struct {
+	blocksDir string
+	xorKey    []byte
+	locations map[string]blockLocation
+	hashes    []string
+	heights   map[string]int32
+}
+
+// NewBlockFileRepo indexes the block headers in blocksDir, the canonical chain is walked back from tipHash
+// (e.g. getbestblockhash), blocks after tipHash are ignored
+func NewBlockFileRepo(blocksDir string, tipHash string) (*BlockFileRepository, error) {
+	b := &BlockFileRepository{
+		blocksDir: blocksDir,
+		locations: map[string]blockLocation{},
+		heights:   map[string]int32{},
+	}
+
+	// bitcoind 28+ obfuscates the block files with the key in xor.dat
+	xorKey, err := os.ReadFile(filepath.Join(blocksDir, "xor.dat"))
+	if err == nil {
+		b.xorKey = xorKey
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for file := 0; ; file++ {
+		if _, err := os.Stat(b.blockFilePath(file)); os.IsNotExist(err) {
+			break
+		}
+
+		if err := b.scanBlockFile(file); err != nil {
+			return nil, fmt.Errorf("failed to scan %s: %w", b.blockFilePath(file), err)
+		}
+	}
+	log.Printf("Found %d blocks in %s", len(b.locations), blocksDir)
+
+	// walk back the canonical chain from the tip to the genesis block
+	hashes := []string{}
+	for hash := tipHash; hash != ""; {
+		location, ok := b.locations[hash]
+		if !ok {
+			return nil, fmt.Errorf("block %s not found in block files", hash)
+		}
+
+		hashes = append(hashes, hash)
+		hash = location.prevHash
+	}
+
+	b.hashes = make([]string, len(hashes))
+	for i, hash := range hashes {
+		height := len(hashes) - 1 - i
+		b.hashes[height] = hash
+		b.heights[hash] = int32(height)
+	}
+
+	return b, nil
+}
+
+func (b *BlockFileRepository) blockFilePath(file int) string {
+	return filepath.Join(b.blocksDir, fmt.Sprintf("blk%05d.dat", file))
+}
+
+func (b *BlockFileRepository) readAt(f *os.File, buf []byte, offset int64) error {
+	if _, err := f.ReadAt(buf, offset); err != nil {
+		return err
+	}
+
+	if len(b.xorKey) > 0 {
+		for i := range buf {
+			buf[i] ^= b.xorKey[(offset+int64(i))%int64(len(b.xorKey))]
+		}
+	}
+
+	return nil
+}
+
+func (b *BlockFileRepository) scanBlockFile(file int) error {
+	f, err := os.Open(b.blockFilePath(file))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	record := make([]byte, 8+BLOCK_HEADER_SIZE)
+	offset := int64(0)
+
+	for {
+		err := b.readAt(f, record, offset)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		magic := binary.LittleEndian.Uint32(record[0:4])
+		if magic == 0 {
+			// the rest of the file is preallocated
+			return nil
+		}
+		if _, ok := blockFileMagics[magic]; !ok {
+			return fmt.Errorf("unknown magic %08x at offset %d", magic, offset)
+		}
+
+		size := binary.LittleEndian.Uint32(record[4:8])
+		header, err := ParseBlockHeader(record[8:])
+		if err != nil {
+			return err
+		}
+
+		b.locations[header.Hash] = blockLocation{
+			file:     file,
+			offset:   offset + 8,
+			size:     size,
+			prevHash: header.Previousblockhash,
+		}
+
+		offset += 8 + int64(size)
+	}
+}
+
+func (b *BlockFileRepository) GetBlockHash(height int32) (string, error) {
+	if height < 0 || int(height) >= len(b.hashes) {
+		return "", &RPCError{Code: RPC_INVALID_PARAMETER, Message: fmt.Sprintf("Block height %d out of range", height)}
+	}
+
+	return b.hashes[height], nil
+}
+
+func (b *BlockFileRepository) GetBlock(blockHash string) (*GetBlock, error) {
+	location, ok := b.locations[blockHash]
+	if !ok {
+		return nil, &RPCError{Code: RPC_INVALID_ADDRESS_OR_KEY, Message: "Block not found"}
+	}
+
+	f, err := os.Open(b.blockFilePath(location.file))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	raw := make([]byte, location.size)
+	if err := b.readAt(f, raw, location.offset); err != nil {
+		return nil, err
+	}
+
+	block, err := ParseRawBlock(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse block %s: %w", blockHash, err)
+	}
+
+	if height, ok := b.heights[blockHash]; ok {
+		block.Height = int(height)
+		block.Confirmations = len(b.hashes) - int(height)
+		if int(height)+1 < len(b.hashes) {
+			block.Nextblockhash = b.hashes[height+1]
+		}
+	} else {
+		// stale block
+		block.Confirmations = -1
+	}
+
+	return block, nil
+}
+
+func (b *BlockFileRepository) GetBlockWithVerbosity(blockHash string, verbosity int32) (*GetBlock, error) {
+	return b.GetBlock(blockHash)
+}
+
+func (b *BlockFileRepository) GetBlockCount() (int32, error) {
+	return int32(len(b.hashes) - 1), nil
+}
@@ -0,0 +1,61 @@
+package bitcoin
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBlockFileRepo_Xor(t *testing.T) {
+	dir := t.TempDir()
+	raw, _ := hex.DecodeString(genesisBlockHex)
+	xorKey := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+
+	record := binary.LittleEndian.AppendUint32(nil, 0xd9b4bef9)
+	record = binary.LittleEndian.AppendUint32(record, uint32(len(raw)))
+	record = append(record, raw...)
+	// preallocated space
+	record = append(record, make([]byte, 16)...)
+
+	for i := range record {
+		record[i] ^= xorKey[i%len(xorKey)]
+	}
+
+	os.WriteFile(filepath.Join(dir, "xor.dat"), xorKey, 0644)
+	os.WriteFile(filepath.Join(dir, "blk00000.dat"), record, 0644)
+
+	genesisHash := "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+	repo, err := NewBlockFileRepo(dir, genesisHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, _ := repo.GetBlockCount()
+	if count != 0 {
+		t.Errorf("Block count should be 0, got %d", count)
+	}
+
+	hash, err := repo.GetBlockHash(0)
+	if err != nil || hash != genesisHash {
+		t.Errorf("Block hash incorrect %s %v", hash, err)
+	}
+
+	// beyond the tip, like bitcoind
+	if _, err := repo.GetBlockHash(1); !IsBlockNotFound(err) {
+		t.Errorf("Out of range height should be not found %v", err)
+	}
+	if _, err := repo.GetBlock(genesisHash[:60] + "0000"); !IsBlockNotFound(err) {
+		t.Errorf("Unknown block should be not found %v", err)
+	}
+
+	block, err := repo.GetBlock(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if block.Height != 0 || block.Confirmations != 1 || len(block.Tx) != 1 {
+		t.Errorf("Block incorrect height %d confirmations %d txs %d", block.Height, block.Confirmations, len(block.Tx))
+	}
+}
@@ -0,0 +1,45 @@
+package bitcoin
+
+import (
+	"errors"
+	"fmt"
+)
+
+// bitcoind rpc error codes
+const (
+	RPC_INVALID_ADDRESS_OR_KEY = -5
+	RPC_INVALID_PARAMETER      = -8
+	RPC_IN_WARMUP              = -28
+)
+
+var ErrUnauthorized = errors.New("bitcoin rpc unauthorized, check BTC_RPC_USER and BTC_RPC_PASSWORD")
+
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("RPC error (code %d): %s", e.Code, e.Message)
+}
+
+func rpcErrorCode(err error) (int, bool) {
+	rpcErr := &RPCError{}
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code, true
+	}
+
+	return 0, false
+}
+
+// IsBlockNotFound returns true for unknown block hashes and heights above the tip
+func IsBlockNotFound(err error) bool {
+	code, ok := rpcErrorCode(err)
+	return ok && (code == RPC_INVALID_ADDRESS_OR_KEY || code == RPC_INVALID_PARAMETER)
+}
+
+// IsWarmingUp returns true while bitcoind is loading its block index
+func IsWarmingUp(err error) bool {
+	code, ok := rpcErrorCode(err)
+	return ok && code == RPC_IN_WARMUP
+}
@@ -0,0 +1,288 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// transactions per page of /block/:hash/txs/:start_index
+const ESPLORA_TXS_PAGE_SIZE = 25
+
+// esplora script types to bitcoind script types
+var esploraScriptTypes = map[string]string{
+	"p2pk":      "pubkey",
+	"p2pkh":     "pubkeyhash",
+	"p2sh":      "scripthash",
+	"v0_p2wpkh": "witness_v0_keyhash",
+	"v0_p2wsh":  "witness_v0_scripthash",
+	"v1_p2tr":   "witness_v1_taproot",
+	"multisig":  "multisig",
+	"op_return": "nulldata",
+	"unknown":   "nonstandard",
+}
+
+type EsploraBlock struct {
+	Id                string  `json:"id"`
+	Height            int     `json:"height"`
+	Version           int     `json:"version"`
+	Timestamp         int     `json:"timestamp"`
+	Mediantime        int     `json:"mediantime"`
+	TxCount           int     `json:"tx_count"`
+	Size              int     `json:"size"`
+	Weight            int     `json:"weight"`
+	MerkleRoot        string  `json:"merkle_root"`
+	Previousblockhash string  `json:"previousblockhash"`
+	Nonce             int     `json:"nonce"`
+	Bits              uint32  `json:"bits"`
+	Difficulty        float64 `json:"difficulty"`
+}
+
+type EsploraTx struct {
+	Txid     string        `json:"txid"`
+	Version  int           `json:"version"`
+	Locktime int           `json:"locktime"`
+	Size     int           `json:"size"`
+	Weight   int           `json:"weight"`
+	Vin      []EsploraVin  `json:"vin"`
+	Vout     []EsploraVout `json:"vout"`
+}
+
+type EsploraVin struct {
+	Txid         string       `json:"txid"`
+	Vout         int64        `json:"vout"`
+	Prevout      *EsploraVout `json:"prevout"`
+	Scriptsig    string       `json:"scriptsig"`
+	ScriptsigAsm string       `json:"scriptsig_asm"`
+	Witness      []string     `json:"witness"`
+	IsCoinbase   bool         `json:"is_coinbase"`
+	Sequence     int64        `json:"sequence"`
+}
+
+type EsploraVout struct {
+	Scriptpubkey        string `json:"scriptpubkey"`
+	ScriptpubkeyAsm     string `json:"scriptpubkey_asm"`
+	ScriptpubkeyType    string `json:"scriptpubkey_type"`
+	ScriptpubkeyAddress string `json:"scriptpubkey_address"`
+	Value               int64  `json:"value"`
+}
+
+// EsploraRepository reads blocks from an Esplora compatible REST API (e.g. https://blockstream.info/api)
+type EsploraRepository struct {
+	url string
+
+	// called before every request, a block takes a request per ESPLORA_TXS_PAGE_SIZE transactions
+	wait func()
+}
+
+func NewEsploraRepo(url string) *EsploraRepository {
+	return &EsploraRepository{url: strings.TrimSuffix(url, "/")}
+}
+
+// SetRequestLimiter makes every request, retries included, wait for wait first
+func (e *EsploraRepository) SetRequestLimiter(wait func()) {
+	e.wait = wait
+}
+
+// get retries with exponential backoff on connection errors, rate limits and 5xx responses,
+// a 404 is returned as a block not found RPCError
+func (e *EsploraRepository) get(path string) ([]byte, error) {
+	var err error
+	delay := RPC_RETRY_DELAY
+
+	for attempt := 1; attempt <= RPC_MAX_ATTEMPTS; attempt++ {
+		if attempt > 1 {
+			log.Printf("esplora request %s failed (attempt %d): %s", path, attempt-1, err)
+			time.Sleep(delay)
+			delay *= 2
+		}
+		if e.wait != nil {
+			e.wait()
+		}
+
+		var res *http.Response
+		res, err = httpClient.Get(e.url + path)
+		if err != nil {
+			continue
+		}
+
+		var body []byte
+		body, err = io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case res.StatusCode == http.StatusOK:
+			return body, nil
+		case res.StatusCode == http.StatusNotFound:
+			return nil, &RPCError{Code: RPC_INVALID_ADDRESS_OR_KEY, Message: strings.TrimSpace(string(body))}
+		case res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500:
+			err = fmt.Errorf("errors.request status code: %d", res.StatusCode)
+		default:
+			return nil, fmt.Errorf("errors.request status code: %d: %s", res.StatusCode, body)
+		}
+	}
+
+	return nil, err
+}
+
+func (e *EsploraRepository) getJson(path string, result interface{}) error {
+	body, err := e.get(path)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("%s: failed to unmarshal response: %w", path, err)
+	}
+
+	return nil
+}
+
+func (e *EsploraRepository) GetBlockHash(height int32) (string, error) {
+	body, err := e.get(fmt.Sprintf("/block-height/%d", height))
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+func (e *EsploraRepository) GetBlock(blockHash string) (*GetBlock, error) {
+	esploraBlock := EsploraBlock{}
+	if err := e.getJson("/block/"+blockHash, &esploraBlock); err != nil {
+		return nil, err
+	}
+
+	block := &GetBlock{
+		Hash:              esploraBlock.Id,
+		Height:            esploraBlock.Height,
+		Version:           esploraBlock.Version,
+		VersionHex:        fmt.Sprintf("%08x", uint32(esploraBlock.Version)),
+		Merkleroot:        esploraBlock.MerkleRoot,
+		Time:              esploraBlock.Timestamp,
+		Mediantime:        esploraBlock.Mediantime,
+		Nonce:             esploraBlock.Nonce,
+		Bits:              fmt.Sprintf("%08x", esploraBlock.Bits),
+		Difficulty:        esploraBlock.Difficulty,
+		NTx:               esploraBlock.TxCount,
+		Previousblockhash: esploraBlock.Previousblockhash,
+		Size:              esploraBlock.Size,
+		Weight:            esploraBlock.Weight,
+		Tx:                make([]Tx, 0, esploraBlock.TxCount),
+	}
+
+	for start := 0; start < esploraBlock.TxCount; start += ESPLORA_TXS_PAGE_SIZE {
+		txs := []EsploraTx{}
+		if err := e.getJson(fmt.Sprintf("/block/%s/txs/%d", blockHash, start), &txs); err != nil {
+			return nil, err
+		}
+
+		for _, tx := range txs {
+			block.Tx = append(block.Tx, tx.toTx())
+		}
+	}
+
+	if len(block.Tx) != block.NTx {
+		return nil, fmt.Errorf("block %s: expected %d txs, got %d", blockHash, block.NTx, len(block.Tx))
+	}
+
+	return block, nil
+}
+
+func (e *EsploraRepository) GetBlockWithVerbosity(blockHash string, verbosity int32) (*GetBlock, error) {
+	return e.GetBlock(blockHash)
+}
+
+func (e *EsploraRepository) GetBlockCount() (int32, error) {
+	body, err := e.get("/blocks/tip/height")
+	if err != nil {
+		return 0, err
+	}
+
+	height, err := strconv.ParseInt(strings.TrimSpace(string(body)), 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid tip height: %w", err)
+	}
+
+	return int32(height), nil
+}
+
+func (tx *EsploraTx) toTx() Tx {
+	result := Tx{
+		Txid:     tx.Txid,
+		Version:  tx.Version,
+		Size:     tx.Size,
+		Vsize:    (tx.Weight + 3) / 4,
+		Weight:   tx.Weight,
+		Locktime: tx.Locktime,
+		Vin:      make([]Vin, 0, len(tx.Vin)),
+		Vout:     make([]Vout, 0, len(tx.Vout)),
+	}
+
+	for _, vin := range tx.Vin {
+		if vin.IsCoinbase {
+			result.Vin = append(result.Vin, Vin{
+				Coinbase:    vin.Scriptsig,
+				Txinwitness: vin.Witness,
+				Sequence:    vin.Sequence,
+			})
+			continue
+		}
+
+		resultVin := Vin{
+			Txid:        vin.Txid,
+			Vout:        vin.Vout,
+			Txinwitness: vin.Witness,
+			Sequence:    vin.Sequence,
+			ScriptSig:   ScriptSig{Asm: vin.ScriptsigAsm, Hex: vin.Scriptsig},
+		}
+		if vin.Prevout != nil {
+			resultVin.PrevOutput = Prevout{
+				Value:        Amount(vin.Prevout.Value),
+				ScriptPubKey: vin.Prevout.toScriptPubKey(),
+			}
+		}
+
+		result.Vin = append(result.Vin, resultVin)
+	}
+
+	for n, vout := range tx.Vout {
+		result.Vout = append(result.Vout, Vout{
+			Value:        Amount(vout.Value),
+			N:            n,
+			ScriptPubKey: vout.toScriptPubKey(),
+		})
+	}
+
+	// esplora doesn't include the raw tx or the wtxid in block txs, both are left empty if the tx can't be serialized
+	if raw, err := SerializeTx(&result); err == nil {
+		wtxid := doubleSHA256(raw)
+		result.Hex = hex.EncodeToString(raw)
+		result.Hash = reversedHex(wtxid[:])
+	}
+
+	return result
+}
+
+func (vout *EsploraVout) toScriptPubKey() ScriptPubKey {
+	scriptType, ok := esploraScriptTypes[vout.ScriptpubkeyType]
+	if !ok {
+		scriptType = vout.ScriptpubkeyType
+	}
+
+	return ScriptPubKey{
+		Asm:     vout.ScriptpubkeyAsm,
+		Hex:     vout.Scriptpubkey,
+		Address: vout.ScriptpubkeyAddress,
+		Type:    scriptType,
+	}
+}
@@ -0,0 +1,105 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEsploraRepo_GetBlock(t *testing.T) {
+	hash := "0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206"
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/blocks/tip/height":
+			fmt.Fprint(w, "1")
+		case r.URL.Path == "/block-height/1":
+			fmt.Fprint(w, hash)
+		case r.URL.Path == "/block/"+hash:
+			fmt.Fprintf(w, `{"id":"%s","height":1,"version":536870912,"timestamp":1296688602,"tx_count":27,"size":1000,"weight":4000,"merkle_root":"aa","previousblockhash":"bb","nonce":2,"bits":545259519}`, hash)
+		case strings.HasPrefix(r.URL.Path, "/block/"+hash+"/txs/"):
+			start := 0
+			fmt.Sscanf(strings.TrimPrefix(r.URL.Path, "/block/"+hash+"/txs/"), "%d", &start)
+
+			txs := []string{}
+			for i := start; i < min(start+ESPLORA_TXS_PAGE_SIZE, 27); i++ {
+				if i == 0 {
+					txs = append(txs, `{"txid":"tx0","version":2,"locktime":0,"size":100,"weight":400,"vin":[{"txid":"0000","vout":4294967295,"prevout":null,"scriptsig":"51","is_coinbase":true,"sequence":4294967295}],"vout":[{"scriptpubkey":"0014aa","scriptpubkey_type":"v0_p2wpkh","scriptpubkey_address":"bcrt1q","value":5000000000}]}`)
+					continue
+				}
+				txs = append(txs, fmt.Sprintf(`{"txid":"tx%d","version":2,"locktime":0,"size":100,"weight":401,"vin":[{"txid":"tx0","vout":0,"prevout":{"scriptpubkey":"0014aa","scriptpubkey_type":"v0_p2wpkh","scriptpubkey_address":"bcrt1q","value":12345},"scriptsig":"","witness":["30","02"],"is_coinbase":false,"sequence":1}],"vout":[]}`, i))
+			}
+			fmt.Fprintf(w, "[%s]", strings.Join(txs, ","))
+		default:
+			http.Error(w, "Block not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	repo := NewEsploraRepo(server.URL + "/")
+	requests := 0
+	repo.SetRequestLimiter(func() { requests++ })
+
+	count, err := repo.GetBlockCount()
+	if err != nil || count != 1 {
+		t.Fatalf("Block count incorrect %d %v", count, err)
+	}
+
+	_, err = repo.GetBlockHash(2)
+	if !IsBlockNotFound(err) {
+		t.Errorf("Expected block not found, got %v", err)
+	}
+
+	blockHash, _ := repo.GetBlockHash(1)
+	block, err := repo.GetBlock(blockHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if block.Hash != hash || block.Height != 1 || block.Bits != "207fffff" || len(block.Tx) != 27 {
+		t.Errorf("Block incorrect %s %d %s %d", block.Hash, block.Height, block.Bits, len(block.Tx))
+	}
+
+	// the tip, 2 block hashes, the block and 2 pages of txs
+	if requests != 6 {
+		t.Errorf("Expected every request to wait for the limiter, got %d waits", requests)
+	}
+
+	coinbase := block.Tx[0]
+	if coinbase.Vin[0].Coinbase != "51" || coinbase.Vout[0].ScriptPubKey.Type != "witness_v0_keyhash" || coinbase.Vout[0].Value != 5_000_000_000 {
+		t.Errorf("Coinbase incorrect %+v", coinbase)
+	}
+
+	spend := block.Tx[26].Vin[0]
+	if spend.Txid != "tx0" || spend.PrevOutput.ScriptPubKey.Hex != "0014aa" || spend.PrevOutput.Value != 12345 || len(spend.Txinwitness) != 2 {
+		t.Errorf("Vin incorrect %+v", spend)
+	}
+}
+
+func TestEsploraTx_Wtxid(t *testing.T) {
+	raw, _ := hex.DecodeString("02000000" + "0001" + "01" + "aa00000000000000000000000000000000000000000000000000000000000000" + "01000000" + "00" + "fdffffff" + "01" + "e803000000000000" + "160014" + "0000000000000000000000000000000000000000" + "02" + "03010203" + "0104" + "00000000")
+	expected, err := ParseRawTx(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	esploraTx := EsploraTx{
+		Txid:    expected.Txid,
+		Version: 2,
+		Vin: []EsploraVin{{
+			Txid:     expected.Vin[0].Txid,
+			Vout:     1,
+			Witness:  []string{"010203", "04"},
+			Sequence: 0xfffffffd,
+		}},
+		Vout: []EsploraVout{{Scriptpubkey: "00140000000000000000000000000000000000000000", Value: 1000}},
+	}
+
+	tx := esploraTx.toTx()
+	if tx.Hash != expected.Hash || tx.Hash == tx.Txid {
+		t.Errorf("Wtxid incorrect %s, expected %s", tx.Hash, expected.Hash)
+	}
+}
@@ -6,3 +6,9 @@ type BitcoinRepositoryInterface interface {
 	GetBlockWithVerbosity(blockHash string, verbosity int32) (*GetBlock, error)
 	GetBlockCount() (int32, error)
 }
+
+// MempoolRepositoryInterface is implemented by bitcoind, esplora has no mempool prevouts
+type MempoolRepositoryInterface interface {
+	GetRawMempool() ([]string, error)
+	GetRawTransactions(txids []string) ([]Tx, error)
+}
@@ -0,0 +1,405 @@
+package bitcoin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const BLOCK_HEADER_SIZE = 80
+
+// ParseRawBlock decodes a serialized block into the getblock json shape,
+// Height, Nextblockhash and the vin prevouts are left empty
+func ParseRawBlock(raw []byte) (*GetBlock, error) {
+	if len(raw) < BLOCK_HEADER_SIZE {
+		return nil, errors.New("block too short")
+	}
+
+	block, err := ParseBlockHeader(raw[:BLOCK_HEADER_SIZE])
+	if err != nil {
+		return nil, err
+	}
+
+	r := &rawReader{bytes.NewReader(raw[BLOCK_HEADER_SIZE:])}
+
+	txCount, err := r.varInt()
+	if err != nil {
+		return nil, err
+	}
+
+	strippedSize := BLOCK_HEADER_SIZE + varIntSize(txCount)
+	block.Tx = make([]Tx, 0, txCount)
+
+	for i := uint64(0); i < txCount; i++ {
+		tx, stripped, err := parseRawTx(r)
+		if err != nil {
+			return nil, fmt.Errorf("tx %d: %w", i, err)
+		}
+
+		strippedSize += stripped
+		block.Tx = append(block.Tx, *tx)
+	}
+
+	block.NTx = len(block.Tx)
+	block.Size = len(raw)
+	block.Strippedsize = strippedSize
+	block.Weight = strippedSize*3 + len(raw)
+
+	return block, nil
+}
+
+func ParseBlockHeader(header []byte) (*GetBlock, error) {
+	if len(header) != BLOCK_HEADER_SIZE {
+		return nil, fmt.Errorf("invalid block header size %d", len(header))
+	}
+
+	version := int32(binary.LittleEndian.Uint32(header[0:4]))
+	bits := binary.LittleEndian.Uint32(header[72:76])
+	hash := doubleSHA256(header)
+
+	previousblockhash := ""
+	if !bytes.Equal(header[4:36], make([]byte, 32)) {
+		previousblockhash = reversedHex(header[4:36])
+	}
+
+	return &GetBlock{
+		Hash:              reversedHex(hash[:]),
+		Version:           int(version),
+		VersionHex:        fmt.Sprintf("%08x", uint32(version)),
+		Previousblockhash: previousblockhash,
+		Merkleroot:        reversedHex(header[36:68]),
+		Time:              int(binary.LittleEndian.Uint32(header[68:72])),
+		Bits:              fmt.Sprintf("%08x", bits),
+		Nonce:             int(binary.LittleEndian.Uint32(header[76:80])),
+	}, nil
+}
+
+// ParseRawTx decodes a serialized transaction, the vin prevouts are left empty
+func ParseRawTx(raw []byte) (*Tx, error) {
+	reader := bytes.NewReader(raw)
+
+	tx, _, err := parseRawTx(&rawReader{reader})
+	if err != nil {
+		return nil, err
+	}
+	if reader.Len() > 0 {
+		return nil, fmt.Errorf("%d trailing bytes after tx", reader.Len())
+	}
+
+	return tx, nil
+}
+
+// parseRawTx returns the tx and its size without witness data
+func parseRawTx(r *rawReader) (*Tx, int, error) {
+	raw := &bytes.Buffer{}
+	stripped := &bytes.Buffer{}
+	tr := &rawReader{io.TeeReader(r, raw)}
+
+	version, err := tr.bytes(4)
+	if err != nil {
+		return nil, 0, err
+	}
+	stripped.Write(version)
+
+	vinCount, err := tr.varInt()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	segwit := false
+	if vinCount == 0 {
+		// segwit marker, followed by the flag
+		flag, err := tr.bytes(1)
+		if err != nil {
+			return nil, 0, err
+		}
+		if flag[0] != 1 {
+			return nil, 0, fmt.Errorf("invalid segwit flag %d", flag[0])
+		}
+
+		segwit = true
+		vinCount, err = tr.varInt()
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	// the stripped serialization is rebuilt from the parsed fields below
+	sr := &rawReader{io.TeeReader(tr, stripped)}
+	writeVarInt(stripped, vinCount)
+
+	tx := &Tx{
+		Version: int(int32(binary.LittleEndian.Uint32(version))),
+		Vin:     make([]Vin, 0, vinCount),
+	}
+
+	for i := uint64(0); i < vinCount; i++ {
+		outpoint, err := sr.bytes(36)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		script, err := sr.varBytes()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		sequence, err := sr.bytes(4)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		vin := Vin{Sequence: int64(binary.LittleEndian.Uint32(sequence))}
+
+		fundingIndex := binary.LittleEndian.Uint32(outpoint[32:36])
+		if bytes.Equal(outpoint[:32], make([]byte, 32)) && fundingIndex == 0xffffffff {
+			vin.Coinbase = hex.EncodeToString(script)
+		} else {
+			vin.Txid = reversedHex(outpoint[:32])
+			vin.Vout = int64(fundingIndex)
+			vin.ScriptSig.Hex = hex.EncodeToString(script)
+		}
+
+		tx.Vin = append(tx.Vin, vin)
+	}
+
+	voutCount, err := sr.varInt()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tx.Vout = make([]Vout, 0, voutCount)
+	for i := uint64(0); i < voutCount; i++ {
+		value, err := sr.bytes(8)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		script, err := sr.varBytes()
+		if err != nil {
+			return nil, 0, err
+		}
+
+		tx.Vout = append(tx.Vout, Vout{
+			Value: Amount(binary.LittleEndian.Uint64(value)),
+			N:     int(i),
+			ScriptPubKey: ScriptPubKey{
+				Hex: hex.EncodeToString(script),
+			},
+		})
+	}
+
+	if segwit {
+		for i := range tx.Vin {
+			itemCount, err := tr.varInt()
+			if err != nil {
+				return nil, 0, err
+			}
+
+			tx.Vin[i].Txinwitness = make([]string, 0, itemCount)
+			for j := uint64(0); j < itemCount; j++ {
+				item, err := tr.varBytes()
+				if err != nil {
+					return nil, 0, err
+				}
+				tx.Vin[i].Txinwitness = append(tx.Vin[i].Txinwitness, hex.EncodeToString(item))
+			}
+		}
+	}
+
+	locktime, err := tr.bytes(4)
+	if err != nil {
+		return nil, 0, err
+	}
+	stripped.Write(locktime)
+
+	txid := doubleSHA256(stripped.Bytes())
+	wtxid := doubleSHA256(raw.Bytes())
+
+	tx.Txid = reversedHex(txid[:])
+	tx.Hash = reversedHex(wtxid[:])
+	tx.Locktime = int(binary.LittleEndian.Uint32(locktime))
+	tx.Size = raw.Len()
+	tx.Weight = stripped.Len()*3 + raw.Len()
+	tx.Vsize = (tx.Weight + 3) / 4
+	tx.Hex = hex.EncodeToString(raw.Bytes())
+
+	return tx, stripped.Len(), nil
+}
+
+// SerializeTx rebuilds the raw transaction from its decoded fields, for sources that don't return the tx hex
+func SerializeTx(tx *Tx) ([]byte, error) {
+	raw := &bytes.Buffer{}
+	buf := make([]byte, 8)
+
+	segwit := false
+	for _, vin := range tx.Vin {
+		if len(vin.Txinwitness) > 0 {
+			segwit = true
+		}
+	}
+
+	binary.LittleEndian.PutUint32(buf, uint32(tx.Version))
+	raw.Write(buf[:4])
+	if segwit {
+		raw.Write([]byte{0x00, 0x01})
+	}
+
+	writeVarInt(raw, uint64(len(tx.Vin)))
+	for _, vin := range tx.Vin {
+		outpoint := make([]byte, 36)
+		script := vin.ScriptSig.Hex
+		if vin.Coinbase != "" {
+			binary.LittleEndian.PutUint32(outpoint[32:], 0xffffffff)
+			script = vin.Coinbase
+		} else {
+			txid, err := hex.DecodeString(vin.Txid)
+			if err != nil || len(txid) != 32 {
+				return nil, fmt.Errorf("invalid funding txid %q", vin.Txid)
+			}
+			for i := range txid {
+				outpoint[31-i] = txid[i]
+			}
+			binary.LittleEndian.PutUint32(outpoint[32:], uint32(vin.Vout))
+		}
+		raw.Write(outpoint)
+
+		if err := writeVarHex(raw, script); err != nil {
+			return nil, err
+		}
+
+		binary.LittleEndian.PutUint32(buf, uint32(vin.Sequence))
+		raw.Write(buf[:4])
+	}
+
+	writeVarInt(raw, uint64(len(tx.Vout)))
+	for _, vout := range tx.Vout {
+		binary.LittleEndian.PutUint64(buf, uint64(vout.Value))
+		raw.Write(buf)
+
+		if err := writeVarHex(raw, vout.ScriptPubKey.Hex); err != nil {
+			return nil, err
+		}
+	}
+
+	if segwit {
+		for _, vin := range tx.Vin {
+			writeVarInt(raw, uint64(len(vin.Txinwitness)))
+			for _, item := range vin.Txinwitness {
+				if err := writeVarHex(raw, item); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	binary.LittleEndian.PutUint32(buf, uint32(tx.Locktime))
+	raw.Write(buf[:4])
+
+	return raw.Bytes(), nil
+}
+
+// writeVarHex writes hex encoded bytes prefixed with their length
+func writeVarHex(w io.Writer, s string) error {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("invalid hex %q: %w", s, err)
+	}
+
+	writeVarInt(w, uint64(len(b)))
+	w.Write(b)
+	return nil
+}
+
+type rawReader struct {
+	io.Reader
+}
+
+func (r *rawReader) bytes(n uint64) ([]byte, error) {
+	buf := make([]byte, n)
+	_, err := io.ReadFull(r, buf)
+	return buf, err
+}
+
+func (r *rawReader) varInt() (uint64, error) {
+	prefix, err := r.bytes(1)
+	if err != nil {
+		return 0, err
+	}
+
+	switch prefix[0] {
+	case 0xfd:
+		b, err := r.bytes(2)
+		return uint64(binary.LittleEndian.Uint16(b)), err
+	case 0xfe:
+		b, err := r.bytes(4)
+		return uint64(binary.LittleEndian.Uint32(b)), err
+	case 0xff:
+		b, err := r.bytes(8)
+		return binary.LittleEndian.Uint64(b), err
+	default:
+		return uint64(prefix[0]), nil
+	}
+}
+
+func (r *rawReader) varBytes() ([]byte, error) {
+	n, err := r.varInt()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.bytes(n)
+}
+
+func varIntSize(n uint64) int {
+	switch {
+	case n < 0xfd:
+		return 1
+	case n <= 0xffff:
+		return 3
+	case n <= 0xffffffff:
+		return 5
+	default:
+		return 9
+	}
+}
+
+func writeVarInt(w io.Writer, n uint64) {
+	buf := make([]byte, 9)
+
+	switch varIntSize(n) {
+	case 1:
+		w.Write([]byte{byte(n)})
+	case 3:
+		buf[0] = 0xfd
+		binary.LittleEndian.PutUint16(buf[1:], uint16(n))
+		w.Write(buf[:3])
+	case 5:
+		buf[0] = 0xfe
+		binary.LittleEndian.PutUint32(buf[1:], uint32(n))
+		w.Write(buf[:5])
+	default:
+		buf[0] = 0xff
+		binary.LittleEndian.PutUint64(buf[1:], n)
+		w.Write(buf)
+	}
+}
+
+func doubleSHA256(b []byte) [32]byte {
+	first := sha256.Sum256(b)
+	return sha256.Sum256(first[:])
+}
+
+// hashes are displayed in reversed byte order
+func reversedHex(b []byte) string {
+	reversed := make([]byte, len(b))
+	for i := range b {
+		reversed[len(b)-1-i] = b[i]
+	}
+
+	return hex.EncodeToString(reversed)
+}
@@ -0,0 +1,138 @@
+package bitcoin
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+const genesisBlockHex = "0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c0101000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+
+func TestParseRawBlock_Genesis(t *testing.T) {
+	raw, _ := hex.DecodeString(genesisBlockHex)
+
+	block, err := ParseRawBlock(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if block.Hash != "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f" {
+		t.Errorf("Block hash incorrect %s", block.Hash)
+	}
+
+	if block.Previousblockhash != "" {
+		t.Errorf("Genesis block should have no previous block, got %s", block.Previousblockhash)
+	}
+
+	if block.Bits != "1d00ffff" || block.Nonce != 2083236893 || block.Time != 1231006505 {
+		t.Errorf("Block header incorrect %s %d %d", block.Bits, block.Nonce, block.Time)
+	}
+
+	if len(block.Tx) != 1 {
+		t.Fatalf("Expected 1 tx, got %d", len(block.Tx))
+	}
+
+	tx := block.Tx[0]
+	if tx.Txid != "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b" || tx.Hash != tx.Txid {
+		t.Errorf("Tx id incorrect %s %s", tx.Txid, tx.Hash)
+	}
+
+	if tx.Vin[0].Coinbase == "" || tx.Vin[0].Txid != "" {
+		t.Error("Genesis tx input should be a coinbase")
+	}
+
+	if tx.Vout[0].Value != 5_000_000_000 {
+		t.Errorf("Coinbase value incorrect %d", tx.Vout[0].Value)
+	}
+
+	if block.Size != len(raw) || block.Weight != len(raw)*4 {
+		t.Errorf("Block size incorrect %d %d", block.Size, block.Weight)
+	}
+}
+
+func TestParseRawBlock_Witness(t *testing.T) {
+	version, _ := hex.DecodeString("02000000")
+	vin, _ := hex.DecodeString("01" + "aa00000000000000000000000000000000000000000000000000000000000000" + "01000000" + "00" + "fdffffff")
+	vout, _ := hex.DecodeString("01" + "e803000000000000" + "160014" + "0000000000000000000000000000000000000000")
+	witness, _ := hex.DecodeString("02" + "03010203" + "0104")
+	locktime, _ := hex.DecodeString("00000000")
+
+	legacyTx := bytes.Join([][]byte{version, vin, vout, locktime}, nil)
+	witnessTx := bytes.Join([][]byte{version, {0x00, 0x01}, vin, vout, witness, locktime}, nil)
+
+	legacy, _, err := parseRawTx(&rawReader{bytes.NewReader(legacyTx)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	segwit, stripped, err := parseRawTx(&rawReader{bytes.NewReader(witnessTx)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if segwit.Txid != legacy.Txid {
+		t.Errorf("Txid should not depend on witness data. %s != %s", segwit.Txid, legacy.Txid)
+	}
+
+	if segwit.Hash == segwit.Txid {
+		t.Error("Witness tx hash should differ from txid")
+	}
+
+	if stripped != len(legacyTx) || segwit.Size != len(witnessTx) {
+		t.Errorf("Tx size incorrect %d %d", stripped, segwit.Size)
+	}
+
+	if len(segwit.Vin[0].Txinwitness) != 2 || segwit.Vin[0].Txinwitness[0] != "010203" || segwit.Vin[0].Txinwitness[1] != "04" {
+		t.Errorf("Witness incorrect %v", segwit.Vin[0].Txinwitness)
+	}
+
+	if segwit.Vin[0].Vout != 1 || segwit.Vout[0].Value != 1000 {
+		t.Errorf("Outpoints incorrect %d %d", segwit.Vin[0].Vout, segwit.Vout[0].Value)
+	}
+}
+
+func TestSerializeTx(t *testing.T) {
+	raw, _ := hex.DecodeString(genesisBlockHex)
+	block, err := ParseRawBlock(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serialized, err := SerializeTx(&block.Tx[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(serialized) != block.Tx[0].Hex {
+		t.Errorf("Coinbase serialization incorrect %x", serialized)
+	}
+
+	witnessTx, _ := hex.DecodeString("02000000" + "0001" + "01" + "aa00000000000000000000000000000000000000000000000000000000000000" + "01000000" + "00" + "fdffffff" + "01" + "e803000000000000" + "160014" + "0000000000000000000000000000000000000000" + "02" + "03010203" + "0104" + "00000000")
+	tx, _, err := parseRawTx(&rawReader{bytes.NewReader(witnessTx)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serialized, err = SerializeTx(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(serialized, witnessTx) {
+		t.Errorf("Witness tx serialization incorrect %x", serialized)
+	}
+}
+
+func TestParseRawTx(t *testing.T) {
+	raw, _ := hex.DecodeString("02000000" + "01" + "aa00000000000000000000000000000000000000000000000000000000000000" + "01000000" + "00" + "fdffffff" + "01" + "e803000000000000" + "160014" + "0000000000000000000000000000000000000000" + "00000000")
+
+	tx, err := ParseRawTx(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Hex != hex.EncodeToString(raw) || len(tx.Vin) != 1 || tx.Vin[0].Vout != 1 || len(tx.Vout) != 1 {
+		t.Errorf("Tx incorrect %+v", tx)
+	}
+
+	if _, err := ParseRawTx(append(raw, 0)); err == nil {
+		t.Error("Trailing bytes should fail")
+	}
+}
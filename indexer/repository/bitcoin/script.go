@@ -0,0 +1,197 @@
+package bitcoin
+
+import (
+	"encoding/hex"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+	"github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+const SCRIPT_TYPE_P2PK = "p2pk"
+const SCRIPT_TYPE_P2PKH = "p2pkh"
+const SCRIPT_TYPE_P2SH = "p2sh"
+const SCRIPT_TYPE_P2WPKH = "p2wpkh"
+const SCRIPT_TYPE_P2WSH = "p2wsh"
+const SCRIPT_TYPE_P2TR = "p2tr"
+const SCRIPT_TYPE_WITNESS_UNKNOWN = "witness_unknown"
+const SCRIPT_TYPE_OP_RETURN = "op_return"
+const SCRIPT_TYPE_MULTISIG = "multisig"
+const SCRIPT_TYPE_NONSTANDARD = "nonstandard"
+
+const DEFAULT_NETWORK = "regtest"
+
+const OP_0 = 0x00
+const OP_1 = 0x51
+const OP_16 = 0x60
+const OP_RETURN = 0x6a
+const OP_DUP = 0x76
+const OP_EQUAL = 0x87
+const OP_EQUALVERIFY = 0x88
+const OP_HASH160 = 0xa9
+const OP_CHECKSIG = 0xac
+const OP_CHECKMULTISIG = 0xae
+
+// address prefixes of a network
+type NetworkParams struct {
+	PubKeyHashAddrID byte
+	ScriptHashAddrID byte
+	Bech32HRP        string
+}
+
+var networkParams = map[string]NetworkParams{
+	"mainnet": {0x00, 0x05, "bc"},
+	"testnet": {0x6f, 0xc4, "tb"},
+	"signet":  {0x6f, 0xc4, "tb"},
+	"regtest": {0x6f, 0xc4, "bcrt"},
+}
+
+// GetNetworkParams defaults to regtest params for unknown networks
+func GetNetworkParams(network string) NetworkParams {
+	params, ok := networkParams[network]
+	if !ok {
+		return networkParams[DEFAULT_NETWORK]
+	}
+
+	return params
+}
+
+// ClassifyScript returns the type of a hex encoded output script
+func ClassifyScript(pkScript string) string {
+	script, err := hex.DecodeString(pkScript)
+	if err != nil {
+		return SCRIPT_TYPE_NONSTANDARD
+	}
+
+	return classifyScript(script)
+}
+
+func classifyScript(script []byte) string {
+	switch {
+	case len(script) > 0 && script[0] == OP_RETURN:
+		return SCRIPT_TYPE_OP_RETURN
+	case len(script) == 25 && script[0] == OP_DUP && script[1] == OP_HASH160 && script[2] == 20 && script[23] == OP_EQUALVERIFY && script[24] == OP_CHECKSIG:
+		return SCRIPT_TYPE_P2PKH
+	case len(script) == 23 && script[0] == OP_HASH160 && script[1] == 20 && script[22] == OP_EQUAL:
+		return SCRIPT_TYPE_P2SH
+	case isPubKeyScript(script):
+		return SCRIPT_TYPE_P2PK
+	case isMultisigScript(script):
+		return SCRIPT_TYPE_MULTISIG
+	}
+
+	version, program, ok := witnessProgram(script)
+	switch {
+	case !ok:
+		return SCRIPT_TYPE_NONSTANDARD
+	case version == 0 && len(program) == 20:
+		return SCRIPT_TYPE_P2WPKH
+	case version == 0 && len(program) == 32:
+		return SCRIPT_TYPE_P2WSH
+	case version == 1 && len(program) == 32:
+		return SCRIPT_TYPE_P2TR
+	case version != 0:
+		return SCRIPT_TYPE_WITNESS_UNKNOWN
+	default:
+		return SCRIPT_TYPE_NONSTANDARD
+	}
+}
+
+// ScriptAddress returns the address of a hex encoded output script on network, empty if the script has no address
+func ScriptAddress(pkScript string, network string) string {
+	script, err := hex.DecodeString(pkScript)
+	if err != nil {
+		return ""
+	}
+
+	params := GetNetworkParams(network)
+
+	switch classifyScript(script) {
+	case SCRIPT_TYPE_P2PKH:
+		return base58.CheckEncode(script[3:23], params.PubKeyHashAddrID)
+	case SCRIPT_TYPE_P2SH:
+		return base58.CheckEncode(script[2:22], params.ScriptHashAddrID)
+	case SCRIPT_TYPE_P2WPKH, SCRIPT_TYPE_P2WSH, SCRIPT_TYPE_P2TR, SCRIPT_TYPE_WITNESS_UNKNOWN:
+		version, program, _ := witnessProgram(script)
+		return segwitAddress(params.Bech32HRP, version, program)
+	default:
+		return ""
+	}
+}
+
+// segwitAddress encodes v0 programs with bech32 and later versions with bech32m
+func segwitAddress(hrp string, version byte, program []byte) string {
+	data, err := bech32.ConvertBits(program, 8, 5, true)
+	if err != nil {
+		return ""
+	}
+	data = append([]byte{version}, data...)
+
+	var address string
+	if version == 0 {
+		address, err = bech32.Encode(hrp, data)
+	} else {
+		address, err = bech32.EncodeM(hrp, data)
+	}
+	if err != nil {
+		return ""
+	}
+
+	return address
+}
+
+// OP_n <2 to 40 bytes>
+func witnessProgram(script []byte) (byte, []byte, bool) {
+	if len(script) < 4 || len(script) > 42 || int(script[1]) != len(script)-2 {
+		return 0, nil, false
+	}
+
+	switch {
+	case script[0] == OP_0:
+		return 0, script[2:], true
+	case script[0] >= OP_1 && script[0] <= OP_16:
+		return script[0] - OP_1 + 1, script[2:], true
+	default:
+		return 0, nil, false
+	}
+}
+
+// <pubkey> OP_CHECKSIG
+func isPubKeyScript(script []byte) bool {
+	return len(script) > 2 && int(script[0]) == len(script)-2 && script[len(script)-1] == OP_CHECKSIG && isPubKey(script[1:len(script)-1])
+}
+
+// OP_m <pubkey>... OP_n OP_CHECKMULTISIG
+func isMultisigScript(script []byte) bool {
+	if len(script) < 3 || script[len(script)-1] != OP_CHECKMULTISIG {
+		return false
+	}
+
+	m, n := script[0], script[len(script)-2]
+	if m < OP_1 || m > OP_16 || n < OP_1 || n > OP_16 || m > n {
+		return false
+	}
+
+	keys := 0
+	for i := 1; i < len(script)-2; {
+		size := int(script[i])
+		if i+1+size > len(script)-2 || !isPubKey(script[i+1:i+1+size]) {
+			return false
+		}
+		keys++
+		i += 1 + size
+	}
+
+	return keys == int(n-OP_1+1)
+}
+
+// compressed or uncompressed public key, by size and prefix
+func isPubKey(key []byte) bool {
+	switch len(key) {
+	case 33:
+		return key[0] == 0x02 || key[0] == 0x03
+	case 65:
+		return key[0] == 0x04 || key[0] == 0x06 || key[0] == 0x07
+	default:
+		return false
+	}
+}
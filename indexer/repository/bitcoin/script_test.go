@@ -0,0 +1,63 @@
+package bitcoin
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcutil/base58"
+)
+
+func TestClassifyScript(t *testing.T) {
+	pubKey := "02" + strings.Repeat("11", 32)
+	tests := map[string]string{
+		"76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac": SCRIPT_TYPE_P2PKH,
+		"a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1887":     SCRIPT_TYPE_P2SH,
+		"21" + pubKey + "ac": SCRIPT_TYPE_P2PK,
+		"51" + "21" + pubKey + "21" + pubKey + "52" + "ae":                     SCRIPT_TYPE_MULTISIG,
+		"0014751e76e8199196d454941c45d1b3a323f1433bd6":                         SCRIPT_TYPE_P2WPKH,
+		"00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262": SCRIPT_TYPE_P2WSH,
+		"512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798": SCRIPT_TYPE_P2TR,
+		"6002751e":     SCRIPT_TYPE_WITNESS_UNKNOWN,
+		"6a0401020304": SCRIPT_TYPE_OP_RETURN,
+		"0013751e76e8199196d454941c45d1b3a323f1433b": SCRIPT_TYPE_NONSTANDARD,
+		"52" + "21" + pubKey + "51" + "ae":           SCRIPT_TYPE_NONSTANDARD,
+		"":                                           SCRIPT_TYPE_NONSTANDARD,
+		"zz":                                         SCRIPT_TYPE_NONSTANDARD,
+	}
+
+	for script, expected := range tests {
+		if scriptType := ClassifyScript(script); scriptType != expected {
+			t.Errorf("Type of %s incorrect, expected %s got %s", script, expected, scriptType)
+		}
+	}
+}
+
+func TestScriptAddress(t *testing.T) {
+	tests := []struct {
+		script  string
+		network string
+		address string
+	}{
+		{"76a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1888ac", "mainnet", "1A1zP1eP5QGefi2DMPTfTL5SLmv7DivfNa"},
+		{"0014751e76e8199196d454941c45d1b3a323f1433bd6", "mainnet", "bc1qw508d6qejxtdg4y5r3zarvary0c5xw7kv8f3t4"},
+		{"00201863143c14c5166804bd19203356da136c985678cd4d27a1b8c6329604903262", "mainnet", "bc1qrp33g0q5c5txsp9arysrx4k6zdkfs4nce4xj0gdcccefvpysxf3qccfmv3"},
+		{"512079be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798", "mainnet", "bc1p0xlxvlhemja6c4dqv22uapctqupfhlxm9h8z3k2e72q4k9hcz7vqzk5jj0"},
+		{"6002751e", "mainnet", "bc1sw50qgdz25j"},
+		{"0014751e76e8199196d454941c45d1b3a323f1433bd6", "testnet", "tb1qw508d6qejxtdg4y5r3zarvary0c5xw7kxpjzsx"},
+		{"0014751e76e8199196d454941c45d1b3a323f1433bd6", "regtest", "bcrt1qw508d6qejxtdg4y5r3zarvary0c5xw7kygt080"},
+		{"6a0401020304", "mainnet", ""},
+		{"21" + "02" + strings.Repeat("11", 32) + "ac", "mainnet", ""},
+	}
+
+	for _, test := range tests {
+		if address := ScriptAddress(test.script, test.network); address != test.address {
+			t.Errorf("Address of %s on %s incorrect, expected %s got %s", test.script, test.network, test.address, address)
+		}
+	}
+
+	address := ScriptAddress("a91462e907b15cbf27d5425399ebf6f0fb50ebb88f1887", "testnet")
+	hash, version, err := base58.CheckDecode(address)
+	if err != nil || version != 0xc4 || len(hash) != 20 || !strings.HasPrefix(address, "2") {
+		t.Errorf("P2sh address incorrect %s %x %v", address, version, err)
+	}
+}
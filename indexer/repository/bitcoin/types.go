@@ -4,16 +4,15 @@ import "encoding/json"
 
 type Request struct {
 	Jsonrpc string            `json:"jsonrpc"`
+	Id      int               `json:"id"`
 	Method  string            `json:"method"`
 	Params  []json.RawMessage `json:"params"`
 }
 
-type GetBlockHashRPC struct {
-	Result string `json:"result"`
-}
-
-type GetBlockRPC struct {
-	Result GetBlock `json:"result"`
+type Response struct {
+	Id     int             `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
 }
 
 type GetBlock struct {
@@ -35,50 +34,52 @@ type GetBlock struct {
 	Strippedsize      int     `json:"strippedsize"`
 	Size              int     `json:"size"`
 	Weight            int     `json:"weight"`
-	Tx                []struct {
-		Txid     string `json:"txid"`
-		Hash     string `json:"hash"`
-		Version  int    `json:"version"`
-		Size     int    `json:"size"`
-		Vsize    int    `json:"vsize"`
-		Weight   int    `json:"weight"`
-		Locktime int    `json:"locktime"`
-		Vin      []struct {
-			Coinbase    string   `json:"coinbase"`
-			Txid        string   `json:"txid"`
-			Vout        int64    `json:"vout"`
-			Txinwitness []string `json:"txinwitness"`
-			Sequence    int64    `json:"sequence"`
-			ScriptSig   struct {
-				Asm string `json:"asm"`
-				Hex string `json:"hex"`
-			} `json:"scriptSig"`
-			PrevOutput struct {
-				Value        float64 `json:"value"`
-				ScriptPubKey struct {
-					Asm     string `json:"asm"`
-					Desc    string `json:"desc"`
-					Hex     string `json:"hex"`
-					Address string `json:"address"`
-					Type    string `json:"type"`
-				} `json:"scriptPubKey"`
-			} `json:"prevout"`
-		} `json:"vin"`
-		Vout []struct {
-			Value        float64 `json:"value"`
-			N            int     `json:"n"`
-			ScriptPubKey struct {
-				Asm     string `json:"asm"`
-				Desc    string `json:"desc"`
-				Hex     string `json:"hex"`
-				Address string `json:"address"`
-				Type    string `json:"type"`
-			} `json:"scriptPubKey"`
-		} `json:"vout"`
-		Hex string `json:"hex"`
-	} `json:"tx"`
+	Tx                []Tx    `json:"tx"`
+}
+
+type Tx struct {
+	Txid     string `json:"txid"`
+	Hash     string `json:"hash"`
+	Version  int    `json:"version"`
+	Size     int    `json:"size"`
+	Vsize    int    `json:"vsize"`
+	Weight   int    `json:"weight"`
+	Locktime int    `json:"locktime"`
+	Vin      []Vin  `json:"vin"`
+	Vout     []Vout `json:"vout"`
+	Hex      string `json:"hex"`
+}
+
+type Vin struct {
+	Coinbase    string    `json:"coinbase"`
+	Txid        string    `json:"txid"`
+	Vout        int64     `json:"vout"`
+	Txinwitness []string  `json:"txinwitness"`
+	Sequence    int64     `json:"sequence"`
+	ScriptSig   ScriptSig `json:"scriptSig"`
+	PrevOutput  Prevout   `json:"prevout"`
+}
+
+type ScriptSig struct {
+	Asm string `json:"asm"`
+	Hex string `json:"hex"`
+}
+
+type Prevout struct {
+	Value        Amount       `json:"value"`
+	ScriptPubKey ScriptPubKey `json:"scriptPubKey"`
+}
+
+type Vout struct {
+	Value        Amount       `json:"value"`
+	N            int          `json:"n"`
+	ScriptPubKey ScriptPubKey `json:"scriptPubKey"`
 }
 
-type GetBlockCountRPC struct {
-	Result int32 `json:"result"`
+type ScriptPubKey struct {
+	Asm     string `json:"asm"`
+	Desc    string `json:"desc"`
+	Hex     string `json:"hex"`
+	Address string `json:"address"`
+	Type    string `json:"type"`
 }
@@ -0,0 +1,227 @@
+package bitcoin
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// bitcoind zmqpubhashblock and zmqpubrawtx topics
+const ZMQ_HASHBLOCK_TOPIC = "hashblock"
+const ZMQ_RAWTX_TOPIC = "rawtx"
+const ZMQ_RECONNECT_DELAY = 5 * time.Second
+
+const (
+	zmtpFlagMore    = 0x01
+	zmtpFlagLong    = 0x02
+	zmtpFlagCommand = 0x04
+)
+
+// SubscribeHashBlock follows the zmqpubhashblock notifications of bitcoind at address (e.g. tcp://127.0.0.1:28332),
+// reconnecting on errors. Notifications are coalesced, only the latest block hash is kept if the reader is behind.
+func SubscribeHashBlock(address string) <-chan string {
+	return subscribeLatest(address, ZMQ_HASHBLOCK_TOPIC, func(body []byte) (string, bool) {
+		return hex.EncodeToString(body), true
+	})
+}
+
+// SubscribeRawTx follows the zmqpubrawtx notifications of bitcoind at address, it sends the txid of the
+// latest transaction accepted to the mempool or mined. Notifications are coalesced like SubscribeHashBlock.
+func SubscribeRawTx(address string) <-chan string {
+	return subscribeLatest(address, ZMQ_RAWTX_TOPIC, func(body []byte) (string, bool) {
+		tx, err := ParseRawTx(body)
+		if err != nil {
+			log.Printf("zmq %s: invalid rawtx: %s", address, err)
+			return "", false
+		}
+
+		return tx.Txid, true
+	})
+}
+
+// subscribeLatest keeps only the latest decoded notification of topic in the returned channel
+func subscribeLatest(address string, topic string, decode func(body []byte) (string, bool)) <-chan string {
+	notifications := make(chan string, 1)
+
+	go func() {
+		for {
+			err := subscribe(address, topic, func(body []byte) {
+				notification, ok := decode(body)
+				if !ok {
+					return
+				}
+
+				select {
+				case <-notifications:
+				default:
+				}
+				notifications <- notification
+			})
+			log.Printf("zmq %s disconnected: %s", address, err)
+
+			time.Sleep(ZMQ_RECONNECT_DELAY)
+		}
+	}()
+
+	return notifications
+}
+
+// subscribe is a minimal ZMTP 3.0 SUB socket with the NULL mechanism,
+// handle is called with the body of every message published on topic
+func subscribe(address string, topic string, handle func(body []byte)) error {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(address, "tcp://"))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+
+	if err := zmtpHandshake(conn, r, "SUB"); err != nil {
+		return err
+	}
+
+	// ZMTP 3.0 subscriptions are messages prefixed with 1
+	if err := zmtpWriteFrame(conn, 0, append([]byte{1}, topic...)); err != nil {
+		return err
+	}
+
+	for {
+		parts, err := zmtpReadMessage(r)
+		if err != nil {
+			return err
+		}
+
+		// topic, body, sequence number
+		if len(parts) >= 2 && string(parts[0]) == topic {
+			handle(parts[1])
+		}
+	}
+}
+
+func zmtpHandshake(conn net.Conn, r *bufio.Reader, socketType string) error {
+	greeting := make([]byte, 64)
+	greeting[0] = 0xff
+	greeting[9] = 0x7f
+	greeting[10] = 3 // major version
+	greeting[11] = 0 // minor version
+	copy(greeting[12:32], "NULL")
+
+	if _, err := conn.Write(greeting); err != nil {
+		return err
+	}
+
+	peerGreeting := make([]byte, 64)
+	if _, err := io.ReadFull(r, peerGreeting); err != nil {
+		return err
+	}
+	if peerGreeting[0] != 0xff || peerGreeting[9] != 0x7f || peerGreeting[10] < 3 {
+		return errors.New("unsupported zmtp peer")
+	}
+	if mechanism := strings.TrimRight(string(peerGreeting[12:32]), "\x00"); mechanism != "NULL" {
+		return fmt.Errorf("unsupported zmtp mechanism %s", mechanism)
+	}
+
+	ready := zmtpCommand("READY", map[string]string{"Socket-Type": socketType})
+	if err := zmtpWriteFrame(conn, zmtpFlagCommand, ready); err != nil {
+		return err
+	}
+
+	flags, body, err := zmtpReadFrame(r)
+	if err != nil {
+		return err
+	}
+	if flags&zmtpFlagCommand == 0 || len(body) < 6 || string(body[1:6]) != "READY" {
+		return errors.New("expected zmtp READY command")
+	}
+
+	return nil
+}
+
+func zmtpCommand(name string, properties map[string]string) []byte {
+	body := append([]byte{byte(len(name))}, name...)
+
+	for key, value := range properties {
+		body = append(body, byte(len(key)))
+		body = append(body, key...)
+		body = binary.BigEndian.AppendUint32(body, uint32(len(value)))
+		body = append(body, value...)
+	}
+
+	return body
+}
+
+func zmtpWriteFrame(w io.Writer, flags byte, body []byte) error {
+	header := []byte{flags}
+	if len(body) > 255 {
+		header[0] |= zmtpFlagLong
+		header = binary.BigEndian.AppendUint64(header, uint64(len(body)))
+	} else {
+		header = append(header, byte(len(body)))
+	}
+
+	_, err := w.Write(append(header, body...))
+	return err
+}
+
+func zmtpReadFrame(r *bufio.Reader) (byte, []byte, error) {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	var size uint64
+	if flags&zmtpFlagLong != 0 {
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(buf)
+	} else {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(b)
+	}
+
+	// bitcoind messages are small, a huge frame means a broken stream
+	if size > 32*1024*1024 {
+		return 0, nil, fmt.Errorf("zmtp frame too large %d", size)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+
+	return flags, body, nil
+}
+
+// zmtpReadMessage reads the frames of the next message, skipping commands
+func zmtpReadMessage(r *bufio.Reader) ([][]byte, error) {
+	parts := [][]byte{}
+
+	for {
+		flags, body, err := zmtpReadFrame(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if flags&zmtpFlagCommand != 0 {
+			continue
+		}
+
+		parts = append(parts, body)
+		if flags&zmtpFlagMore == 0 {
+			return parts, nil
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package bitcoin
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestSubscribeHashBlock(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	hash := "000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+
+	// fake bitcoind publisher
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+
+		if err := zmtpHandshake(conn, r, "PUB"); err != nil {
+			t.Error(err)
+			return
+		}
+
+		parts, err := zmtpReadMessage(r)
+		if err != nil || len(parts) != 1 || string(parts[0]) != "\x01"+ZMQ_HASHBLOCK_TOPIC {
+			t.Errorf("Invalid subscription %q %v", parts, err)
+			return
+		}
+
+		body, _ := hex.DecodeString(hash)
+		zmtpWriteFrame(conn, zmtpFlagMore, []byte("rawtx"))
+		zmtpWriteFrame(conn, 0, []byte{0})
+		zmtpWriteFrame(conn, zmtpFlagMore, []byte(ZMQ_HASHBLOCK_TOPIC))
+		zmtpWriteFrame(conn, zmtpFlagMore, body)
+		zmtpWriteFrame(conn, 0, binary.LittleEndian.AppendUint32(nil, 1))
+
+		time.Sleep(time.Second)
+	}()
+
+	select {
+	case notified := <-SubscribeHashBlock("tcp://" + listener.Addr().String()):
+		if notified != hash {
+			t.Errorf("Block hash incorrect %s", notified)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("No hashblock notification")
+	}
+}
@@ -0,0 +1,227 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/libsv/go-bt/v2/bscript"
+	"gorm.io/gorm"
+)
+
+// bump SCRIPT_ASM_VERSION when the parsing below changes, older rows are parsed again in the background on startup.
+// 1: witness_asm_scripts was the tapscript of any script path spend
+// 2: witness_asm_scripts is the second item of 3 item witnesses again, as smart indexes read it before versioning
+const SCRIPT_ASM_VERSION = 2
+const INDEXER_SCRIPT_ASM_VERSION_KEY = "INDEXER_SCRIPT_ASM_VERSION_KEY"
+const SCRIPT_ASM_BACKFILL_BATCH_SIZE = 1000
+const SCRIPT_ASM_BACKFILL_RETRY_DELAY = 10 * time.Second
+
+// SetAsmScripts parses the signature script, spent output script and tapscript of the input
+func (v *Vin) SetAsmScripts() {
+	v.P2shAsmScripts = nil
+	if v.SignatureScript != "" {
+		scripts, err := ParseP2shSigHexToAsms(v.SignatureScript)
+		if err == nil {
+			v.P2shAsmScripts = scripts
+		}
+	}
+
+	v.PkAsmScripts = pkAsmScripts(v.PkScript)
+	v.WitnessAsmScripts = witnessAsmScripts(v)
+	v.AsmVersion = SCRIPT_ASM_VERSION
+}
+
+// SetAsmScripts parses the output script
+func (v *Vout) SetAsmScripts() {
+	v.PkAsmScripts = pkAsmScripts(v.PkScript)
+	v.AsmVersion = SCRIPT_ASM_VERSION
+}
+
+func pkAsmScripts(pkScript string) *[]string {
+	if pkScript == "" {
+		return nil
+	}
+
+	bs, err := bscript.NewFromHexString(pkScript)
+	if err != nil {
+		return nil
+	}
+
+	asm, err := bs.ToASM()
+	if err != nil {
+		return nil
+	}
+
+	scripts := strings.Split(asm, " ")
+	return &scripts
+}
+
+// witnessAsmScripts is the second item of 3 item witnesses, e.g. the tapscript of a script path spend without annex.
+// Smart indexes rely on it as is, the getWitness host function decodes witnesses following BIP341
+func witnessAsmScripts(vin *Vin) *[]string {
+	stack := vin.Stack()
+	if len(stack) != 3 {
+		return nil
+	}
+
+	return pkAsmScripts(stack[1])
+}
+
+// AfterFind parses the scripts of rows the backfill didn't reach yet
+func (v *Vin) AfterFind(tx *gorm.DB) error {
+	if v.AsmVersion < SCRIPT_ASM_VERSION {
+		v.SetAsmScripts()
+	}
+
+	return nil
+}
+
+func (v *Vout) AfterFind(tx *gorm.DB) error {
+	if v.AsmVersion < SCRIPT_ASM_VERSION {
+		v.SetAsmScripts()
+	}
+
+	return nil
+}
+
+// backfillAsmScripts runs BackfillAsmScripts until it succeeds, it's resumable so failed runs
+// (e.g. a conflict with the indexer) are retried
+func backfillAsmScripts(d *DBRepository) {
+	for {
+		err := d.BackfillAsmScripts()
+		if err == nil {
+			return
+		}
+
+		log.Printf("Script asm backfill failed, retrying: %s", err)
+		time.Sleep(SCRIPT_ASM_BACKFILL_RETRY_DELAY)
+	}
+}
+
+// BackfillAsmScripts parses the scripts of rows indexed before the current SCRIPT_ASM_VERSION,
+// reads of rows not backfilled yet parse them in AfterFind. Every batch is its own Dolt commit,
+// so the indexer flushes blocks in between
+func (d *DBRepository) BackfillAsmScripts() error {
+	value, err := getIndexerValue(d.Db, INDEXER_SCRIPT_ASM_VERSION_KEY)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return err
+	}
+	if version, _ := strconv.Atoi(value); version >= SCRIPT_ASM_VERSION {
+		return nil
+	}
+
+	log.Printf("Backfilling script asm version %d", SCRIPT_ASM_VERSION)
+
+	message := fmt.Sprintf("Backfill script asm version %d", SCRIPT_ASM_VERSION)
+	for _, backfill := range []struct {
+		rows  string
+		batch func(tx *gorm.DB) (int, error)
+	}{{"vins", backfillVinAsmScripts}, {"vouts", backfillVoutAsmScripts}} {
+		for backfilled := 0; ; {
+			count := 0
+			err := d.CommitTransaction(message, func(tx *gorm.DB) error {
+				var err error
+				count, err = backfill.batch(tx)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if count == 0 {
+				break
+			}
+
+			backfilled += count
+			log.Printf("Backfilled script asm of %d %s", backfilled, backfill.rows)
+		}
+	}
+
+	err = d.CommitTransaction(message, func(tx *gorm.DB) error {
+		return setIndexerValue(tx, INDEXER_SCRIPT_ASM_VERSION_KEY, strconv.Itoa(SCRIPT_ASM_VERSION))
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Backfilled script asm version %d", SCRIPT_ASM_VERSION)
+
+	return nil
+}
+
+// asmJson is the stored form of a parsed script column, NULL if it couldn't be parsed
+func asmJson(scripts interface{}) (interface{}, error) {
+	b, err := json.Marshal(scripts)
+	if err != nil || string(b) == "null" {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+// backfillVinAsmScripts updates the next batch of vins, updated rows leave the asm_version filter
+// so every batch reads from the start. AfterFind already parsed them
+func backfillVinAsmScripts(tx *gorm.DB) (int, error) {
+	vins := []*Vin{}
+	res := tx.Where("asm_version < ?", SCRIPT_ASM_VERSION).Limit(SCRIPT_ASM_BACKFILL_BATCH_SIZE).Find(&vins)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if len(vins) == 0 {
+		return 0, nil
+	}
+
+	outpoints := make([][]interface{}, 0, len(vins))
+	columns := map[string][]interface{}{}
+	for _, vin := range vins {
+		outpoints = append(outpoints, []interface{}{vin.TxHash, vin.TxIndex})
+
+		for column, scripts := range map[string]interface{}{"p2sh_asm_scripts": vin.P2shAsmScripts, "pk_asm_scripts": vin.PkAsmScripts, "witness_asm_scripts": vin.WitnessAsmScripts} {
+			value, err := asmJson(scripts)
+			if err != nil {
+				return 0, err
+			}
+			columns[column] = append(columns[column], value)
+		}
+		columns["asm_version"] = append(columns["asm_version"], vin.AsmVersion)
+	}
+
+	if err := updateByOutpoint(tx, &Vin{}, outpoints, columns); err != nil {
+		return 0, fmt.Errorf("backfill vin asm scripts: %w", err)
+	}
+
+	return len(vins), nil
+}
+
+func backfillVoutAsmScripts(tx *gorm.DB) (int, error) {
+	vouts := []*Vout{}
+	res := tx.Where("asm_version < ?", SCRIPT_ASM_VERSION).Limit(SCRIPT_ASM_BACKFILL_BATCH_SIZE).Find(&vouts)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+	if len(vouts) == 0 {
+		return 0, nil
+	}
+
+	outpoints := make([][]interface{}, 0, len(vouts))
+	columns := map[string][]interface{}{}
+	for _, vout := range vouts {
+		outpoints = append(outpoints, []interface{}{vout.TxHash, vout.TxIndex})
+
+		value, err := asmJson(vout.PkAsmScripts)
+		if err != nil {
+			return 0, err
+		}
+		columns["pk_asm_scripts"] = append(columns["pk_asm_scripts"], value)
+		columns["asm_version"] = append(columns["asm_version"], vout.AsmVersion)
+	}
+
+	if err := updateByOutpoint(tx, &Vout{}, outpoints, columns); err != nil {
+		return 0, fmt.Errorf("backfill vout asm scripts: %w", err)
+	}
+
+	return len(vouts), nil
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetAsmScripts(t *testing.T) {
+	p2pkh := "76a914" + strings.Repeat("aa", 20) + "88ac"
+	vout := Vout{PkScript: p2pkh}
+	vout.SetAsmScripts()
+	if vout.PkAsmScripts == nil || strings.Join(*vout.PkAsmScripts, " ") != "OP_DUP OP_HASH160 "+strings.Repeat("aa", 20)+" OP_EQUALVERIFY OP_CHECKSIG" {
+		t.Errorf("Vout pk asm incorrect %v", vout.PkAsmScripts)
+	}
+	if vout.AsmVersion != SCRIPT_ASM_VERSION {
+		t.Errorf("Vout asm version incorrect %d", vout.AsmVersion)
+	}
+
+	taproot := "5120" + strings.Repeat("aa", 32)
+	vin := Vin{
+		PkScript:     taproot,
+		WitnessStack: WitnessStack{strings.Repeat("cc", 64), "0063036f726468", "c1" + strings.Repeat("bb", 32)},
+	}
+	vin.SetAsmScripts()
+	if vin.P2shAsmScripts != nil {
+		t.Errorf("Vin without signature script should not have p2sh asm %v", vin.P2shAsmScripts)
+	}
+	if vin.PkAsmScripts == nil || (*vin.PkAsmScripts)[0] != "OP_TRUE" {
+		t.Errorf("Vin pk asm incorrect %v", vin.PkAsmScripts)
+	}
+	if vin.WitnessAsmScripts == nil || strings.Join(*vin.WitnessAsmScripts, " ") != "OP_FALSE OP_IF 6f7264 OP_ENDIF" {
+		t.Errorf("Vin witness asm incorrect %v", vin.WitnessAsmScripts)
+	}
+	if vin.AsmVersion != SCRIPT_ASM_VERSION {
+		t.Errorf("Vin asm version incorrect %d", vin.AsmVersion)
+	}
+
+	// only 3 item witnesses have witness asm, an annex makes it 4
+	annexed := Vin{PkScript: taproot, WitnessStack: append(vin.WitnessStack, "50ee")}
+	annexed.SetAsmScripts()
+	if annexed.WitnessAsmScripts != nil {
+		t.Errorf("Vin with 4 witness items should not have witness asm %v", annexed.WitnessAsmScripts)
+	}
+
+	outdated := Vout{PkScript: p2pkh}
+	if err := outdated.AfterFind(nil); err != nil || outdated.PkAsmScripts == nil || outdated.AsmVersion != SCRIPT_ASM_VERSION {
+		t.Errorf("Rows read before the backfill should be parsed %+v", outdated)
+	}
+
+	coinbase := Vin{}
+	coinbase.SetAsmScripts()
+	if coinbase.PkAsmScripts != nil || coinbase.WitnessAsmScripts != nil {
+		t.Errorf("Coinbase vin should not have asm %+v", coinbase)
+	}
+}
@@ -1,14 +1,35 @@
 package db
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/libsv/go-bt/v2/bscript"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+// outpoints per vout lookup or spending update, well below the placeholder limit
+const VOUT_BATCH_SIZE = 1000
+
+// DOLT_COMMIT stages every change of the working set, writes ending in a commit hold commitMu
+// so one commit doesn't pick up the partial writes of another
+var commitMu sync.Mutex
+
+// CommitTransaction runs fn in a transaction and commits it to the Dolt history
+func (d *DBRepository) CommitTransaction(message string, fn func(tx *gorm.DB) error) error {
+	commitMu.Lock()
+	defer commitMu.Unlock()
+
+	if err := d.Db.Transaction(fn); err != nil {
+		return err
+	}
+
+	return d.Db.Exec("CALL DOLT_COMMIT('--allow-empty', '-Am', ?);", message).Error
+}
+
 type DBRepository struct {
 	Db *gorm.DB
 }
@@ -84,30 +105,104 @@ func ParseP2shSigHexToAsms(hex string) (*P2shAsmScripts, error) {
 }
 
 func (d *DBRepository) SetLastHeight(height int32) error {
-	indexer := Indexer{
-		Key:   INDEXER_LAST_HEIGHT_KEY,
-		Value: strconv.Itoa(int(height)),
+	return setIndexerValue(d.Db, INDEXER_LAST_HEIGHT_KEY, strconv.Itoa(int(height)))
+}
+
+func (d *DBRepository) SetLastHeightWithTx(tx *gorm.DB, height int32) error {
+	return setIndexerValue(tx, INDEXER_LAST_HEIGHT_KEY, strconv.Itoa(int(height)))
+}
+
+func (d *DBRepository) GetLastHeight() (int32, error) {
+	value, err := getIndexerValue(d.Db, INDEXER_LAST_HEIGHT_KEY)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return -1, nil
+		}
+
+		return 0, err
 	}
 
-	res := d.Db.Model(Indexer{}).Where("`key` = ?", INDEXER_LAST_HEIGHT_KEY).Updates(&indexer)
-	if res.Error != nil {
-		return res.Error
+	height, _ := strconv.Atoi(value)
+	return int32(height), nil
+}
+
+// GetPrunedHeight returns the height up to which vins and spent vouts are pruned, -1 if nothing is pruned
+func (d *DBRepository) GetPrunedHeight() (int32, error) {
+	value, err := getIndexerValue(d.Db, INDEXER_PRUNED_HEIGHT_KEY)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return -1, nil
+		}
+
+		return 0, err
 	}
-	if res.RowsAffected > 0 {
+
+	height, _ := strconv.Atoi(value)
+	return int32(height), nil
+}
+
+// PruneToHeight deletes the vins, the spent vouts and the raw transaction hex of the blocks up to height.
+// Blocks, transactions and unspent vouts are kept, so new blocks still resolve their prevouts
+func (d *DBRepository) PruneToHeight(height int32) error {
+	prunedHeight, err := d.GetPrunedHeight()
+	if err != nil {
+		return err
+	}
+	if height <= prunedHeight {
 		return nil
 	}
 
-	res = d.Db.Save(&indexer)
-	return res.Error
+	return d.CommitTransaction(fmt.Sprintf("Prune to block %d", height), func(tx *gorm.DB) error {
+		if err := tx.Where("block_height > ? AND block_height <= ?", prunedHeight, height).Delete(&Vin{}).Error; err != nil {
+			return err
+		}
+
+		err := tx.Where("spending_tx_hash != '' AND spending_block_height > ? AND spending_block_height <= ?", prunedHeight, height).Delete(&Vout{}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(&Transaction{}).Where("block_height > ? AND block_height <= ? AND hex != ''", prunedHeight, height).Update("hex", "").Error
+		if err != nil {
+			return err
+		}
+
+		return setIndexerValue(tx, INDEXER_PRUNED_HEIGHT_KEY, strconv.Itoa(int(height)))
+	})
 }
 
-func (d *DBRepository) SetLastHeightWithTx(tx *gorm.DB, height int32) error {
+// SquashHistory replaces the Dolt history with a single commit of the working set and garbage collects
+// the chunks only the dropped commits referenced, e.g. the rows deleted by PruneToHeight.
+// It's a maintenance command, the sessions of other processes on the DB don't survive the garbage collection
+func (d *DBRepository) SquashHistory(message string) error {
+	commitMu.Lock()
+	defer commitMu.Unlock()
+
+	var root string
+	if err := d.Db.Raw("SELECT commit_hash FROM dolt_log ORDER BY date ASC LIMIT 1").Scan(&root).Error; err != nil {
+		return err
+	}
+
+	if err := d.Db.Exec("CALL DOLT_RESET('--soft', ?);", root).Error; err != nil {
+		return fmt.Errorf("failed to reset to the root commit: %w", err)
+	}
+	if err := d.Db.Exec("CALL DOLT_COMMIT('--allow-empty', '-Am', ?);", message).Error; err != nil {
+		return err
+	}
+	if err := d.Db.Exec("CALL DOLT_GC();").Error; err != nil {
+		return fmt.Errorf("failed to garbage collect: %w", err)
+	}
+
+	return nil
+}
+
+func setIndexerValue(tx *gorm.DB, key string, value string) error {
 	indexer := Indexer{
-		Key:   INDEXER_LAST_HEIGHT_KEY,
-		Value: strconv.Itoa(int(height)),
+		Key:   key,
+		Value: value,
 	}
 
-	res := tx.Model(Indexer{}).Where("`key` = ?", INDEXER_LAST_HEIGHT_KEY).Updates(&indexer)
+	res := tx.Model(Indexer{}).Where("`key` = ?", key).Updates(&indexer)
 	if res.Error != nil {
 		return res.Error
 	}
@@ -119,21 +214,14 @@ func (d *DBRepository) SetLastHeightWithTx(tx *gorm.DB, height int32) error {
 	return res.Error
 }
 
-func (d *DBRepository) GetLastHeight() (int32, error) {
-	indexer := Indexer{
-		Key: INDEXER_LAST_HEIGHT_KEY,
-	}
-	res := d.Db.First(&indexer)
+func getIndexerValue(tx *gorm.DB, key string) (string, error) {
+	indexer := Indexer{}
+	res := tx.Where("`key` = ?", key).First(&indexer)
 	if res.Error != nil {
-		if res.Error == gorm.ErrRecordNotFound {
-			return -1, nil
-		}
-
-		return 0, res.Error
+		return "", res.Error
 	}
 
-	height, _ := strconv.Atoi(indexer.Value)
-	return int32(height), nil
+	return indexer.Value, nil
 }
 
 func (d *DBRepository) CreateBlock(block *Block) error {
@@ -224,6 +312,238 @@ func (d *DBRepository) CreateVoutWithTx(tx *gorm.DB, outpoint *Vout) error {
 	return tx.Create(outpoint).Error
 }
 
+func (d *DBRepository) GetVout(txHash string, txIndex uint32) (*Vout, error) {
+	vout := Vout{}
+	res := d.Db.Where("tx_hash = ? AND tx_index = ?", txHash, txIndex).First(&vout)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+
+		return nil, res.Error
+	}
+
+	return &vout, nil
+}
+
+// MarkVoutsSpent sets the spending vin of the vouts funding vins, with one update per VOUT_BATCH_SIZE vins
+func (d *DBRepository) MarkVoutsSpent(vins []Vin) error {
+	spending := make([]Vin, 0, len(vins))
+	for _, vin := range vins {
+		if vin.FundingTxHash != "" {
+			spending = append(spending, vin)
+		}
+	}
+
+	for start := 0; start < len(spending); start += VOUT_BATCH_SIZE {
+		batch := spending[start:min(start+VOUT_BATCH_SIZE, len(spending))]
+
+		outpoints := make([][]interface{}, 0, len(batch))
+		columns := map[string][]interface{}{}
+		for _, vin := range batch {
+			outpoints = append(outpoints, []interface{}{vin.FundingTxHash, vin.FundingTxIndex})
+			columns["spending_tx_hash"] = append(columns["spending_tx_hash"], vin.TxHash)
+			columns["spending_tx_index"] = append(columns["spending_tx_index"], vin.TxIndex)
+			columns["spending_block_height"] = append(columns["spending_block_height"], vin.BlockHeight)
+		}
+
+		if err := updateByOutpoint(d.Db, &Vout{}, outpoints, columns); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// updateByOutpoint sets columns of the model rows at outpoints ([tx_hash, tx_index] pairs) in one statement,
+// columns[column][i] is the value of the row at outpoints[i]
+func updateByOutpoint(tx *gorm.DB, model interface{}, outpoints [][]interface{}, columns map[string][]interface{}) error {
+	txHashes := make([]interface{}, 0, len(outpoints))
+	for _, outpoint := range outpoints {
+		txHashes = append(txHashes, outpoint[0])
+	}
+
+	when := strings.Repeat(" WHEN ? THEN ?", len(outpoints))
+	updates := map[string]interface{}{}
+	for column, values := range columns {
+		args := make([]interface{}, 0, 2*len(outpoints))
+		for i, outpoint := range outpoints {
+			args = append(args, fmt.Sprintf("%v:%v", outpoint[0], outpoint[1]), values[i])
+		}
+		updates[column] = gorm.Expr("CASE CONCAT(tx_hash, ':', tx_index)"+when+" END", args...)
+	}
+
+	// tx_hash IN uses idx_tx_hash
+	return tx.Model(model).
+		Where("tx_hash IN ? AND (tx_hash, tx_index) IN ?", txHashes, outpoints).
+		Updates(updates).Error
+}
+
+// GetVoutsByOutpoints returns the indexed vouts of outpoints by OutpointKey, missing ones are left out
+func (d *DBRepository) GetVoutsByOutpoints(outpoints [][]interface{}) (map[string]*Vout, error) {
+	vouts := map[string]*Vout{}
+
+	for start := 0; start < len(outpoints); start += VOUT_BATCH_SIZE {
+		batch := outpoints[start:min(start+VOUT_BATCH_SIZE, len(outpoints))]
+
+		txHashes := make([]interface{}, 0, len(batch))
+		for _, outpoint := range batch {
+			txHashes = append(txHashes, outpoint[0])
+		}
+
+		found := []*Vout{}
+		res := d.Db.Where("tx_hash IN ? AND (tx_hash, tx_index) IN ?", txHashes, batch).Find(&found)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+
+		for _, vout := range found {
+			vouts[OutpointKey(vout.TxHash, vout.TxIndex)] = vout
+		}
+	}
+
+	return vouts, nil
+}
+
+// OutpointKey is the txid:vout of an output
+func OutpointKey(txHash string, txIndex uint32) string {
+	return fmt.Sprintf("%s:%d", txHash, txIndex)
+}
+
+func (d *DBRepository) GetUnspentOutpointsByAddress(address string) ([]*OutPoint, error) {
+	vouts := []*Vout{}
+	res := d.Db.Order("block_height asc, block_tx_index asc, tx_index asc").
+		Where("spender = ? AND spending_tx_hash = ''", address).Find(&vouts)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	outpoints := make([]*OutPoint, 0, len(vouts))
+	for _, vout := range vouts {
+		outpoints = append(outpoints, vout.ToOutPoint())
+	}
+
+	return outpoints, nil
+}
+
+// GetUnspentOutpoint returns nil if the outpoint is unknown or spent
+func (d *DBRepository) GetUnspentOutpoint(txHash string, txIndex uint32) (*OutPoint, error) {
+	vout, err := d.GetVout(txHash, txIndex)
+	if err != nil || vout == nil || vout.SpendingTxHash != "" {
+		return nil, err
+	}
+
+	return vout.ToOutPoint(), nil
+}
+
+func (d *DBRepository) CreateAddressHistory(history *[]AddressHistory) error {
+	if len(*history) == 0 {
+		return nil
+	}
+
+	return d.Db.CreateInBatches(history, 1024).Error
+}
+
+func (d *DBRepository) CreateRunestones(runestones *[]Runestone) error {
+	if len(*runestones) == 0 {
+		return nil
+	}
+
+	return d.Db.CreateInBatches(runestones, 1024).Error
+}
+
+func (d *DBRepository) GetRunestonesByBlockHeight(height uint64) ([]*Runestone, error) {
+	runestones := []*Runestone{}
+	res := d.Db.Order("block_tx_index asc").Where("block_height = ?", height).Find(&runestones)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	return runestones, nil
+}
+
+func (d *DBRepository) CreateInscriptions(inscriptions *[]Inscription) error {
+	if len(*inscriptions) == 0 {
+		return nil
+	}
+
+	return d.Db.CreateInBatches(inscriptions, 128).Error
+}
+
+// GetInscription returns nil if the inscription is unknown
+func (d *DBRepository) GetInscription(id string) (*Inscription, error) {
+	inscription := Inscription{}
+	res := d.Db.Where("id = ?", id).First(&inscription)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+
+		return nil, res.Error
+	}
+
+	return &inscription, nil
+}
+
+func (d *DBRepository) GetInscriptionsByBlockHeight(height uint64) ([]*Inscription, error) {
+	inscriptions := []*Inscription{}
+	res := d.Db.Order("block_tx_index asc, input asc, `offset` asc").Where("block_height = ?", height).Find(&inscriptions)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	return inscriptions, nil
+}
+
+// GetAddressHistory returns the transactions of an address, latest first
+func (d *DBRepository) GetAddressHistory(address string, offset int, limit int) ([]*AddressHistory, error) {
+	if limit <= 0 || limit > MAX_ADDRESS_HISTORY_LIMIT {
+		limit = MAX_ADDRESS_HISTORY_LIMIT
+	}
+
+	history := []*AddressHistory{}
+	res := d.Db.Order("block_height desc, block_tx_index desc").
+		Where("address = ?", address).Offset(offset).Limit(limit).Find(&history)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	return history, nil
+}
+
+// GetAddressBalance sums the history of an address up to height, a negative height is the last indexed height
+func (d *DBRepository) GetAddressBalance(address string, height int64) (*AddressBalance, error) {
+	if height < 0 {
+		lastHeight, err := d.GetLastHeight()
+		if err != nil {
+			return nil, err
+		}
+		height = int64(lastHeight)
+	}
+
+	totals := struct {
+		Received int64
+		Sent     int64
+		TxCount  int64
+	}{}
+	err := d.Db.Model(&AddressHistory{}).
+		Select("COALESCE(SUM(received), 0) AS received, COALESCE(SUM(sent), 0) AS sent, COUNT(*) AS tx_count").
+		Where("address = ? AND block_height <= ?", address, height).
+		Scan(&totals).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &AddressBalance{
+		Address:  address,
+		Height:   height,
+		Received: totals.Received,
+		Sent:     totals.Sent,
+		Balance:  totals.Received - totals.Sent,
+		TxCount:  totals.TxCount,
+	}, nil
+}
+
 func (d *DBRepository) GetBlockByHeight(height int64) (*Block, error) {
 	block := &Block{}
 	if resp := d.Db.First(block, "height = ? AND is_orphan = ?", height, false); resp.Error != nil {
@@ -232,6 +552,22 @@ func (d *DBRepository) GetBlockByHeight(height int64) (*Block, error) {
 	return block, nil
 }
 
+// GetBlockByHash returns the block with hash, orphaned or not, nil if it isn't indexed.
+// A block orphaned and indexed again after a reorg back has both rows, the canonical one is returned
+func (d *DBRepository) GetBlockByHash(hash string) (*Block, error) {
+	block := &Block{}
+	res := d.Db.Order("is_orphan asc").Where("hash = ?", hash).First(block)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+
+		return nil, res.Error
+	}
+
+	return block, nil
+}
+
 func (d *DBRepository) GetBlockByHeightWithIsOrphan(height int64, isOrphan bool) (*Block, error) {
 	block := &Block{}
 	if resp := d.Db.First(block, "height = ? AND is_orphan = ?", height, isOrphan); resp.Error != nil {
@@ -301,57 +637,74 @@ func (d *DBRepository) GetOutpointsByTransactionHash(transactionHash string) ([]
 		})
 	}
 
-	// TODO: handle these errors
-	for i, v := range outpoints {
-		if v.SignatureScript != "" {
-			scripts, err := ParseP2shSigHexToAsms(v.SignatureScript)
-			if err == nil {
-				outpoints[i].P2shAsmScripts = scripts
-			}
+	return outpoints, nil
+}
+
+func (d *DBRepository) UpdateBlocksAsOrphan(height int32) error {
+	// Update blocks
+	if err := d.Db.Model(&Block{}).Where("height >= ?", height).Update("is_orphan", true).Error; err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RollbackToHeight orphans the blocks above height and deletes their transactions, vins and vouts
+// so the replacement blocks can be indexed, the previous state stays in the Dolt history until it's squashed.
+// The reorg of depth blocks is recorded for the indexer status
+func (d *DBRepository) RollbackToHeight(height int32, depth int32) error {
+	return d.CommitTransaction(fmt.Sprintf("Rollback to block %d", height), func(tx *gorm.DB) error {
+		if err := tx.Model(&Block{}).Where("height > ?", height).Update("is_orphan", true).Error; err != nil {
+			return err
 		}
 
-		if v.PkScript != "" {
-			bs, err := bscript.NewFromHexString(v.PkScript)
-			if err == nil {
-				asm, err := bs.ToASM()
-				if err == nil {
-					scripts := strings.Split(asm, " ")
-					outpoints[i].PkAsmScripts = &scripts
-				}
+		for _, model := range []interface{}{&Transaction{}, &Vin{}, &Vout{}, &AddressHistory{}, &Runestone{}, &Inscription{}} {
+			if err := tx.Where("block_height > ?", height).Delete(model).Error; err != nil {
+				return err
 			}
+		}
 
+		// outputs spent by the orphaned blocks are unspent again
+		err := tx.Model(&Vout{}).Where("spending_tx_hash != '' AND spending_block_height > ?", height).
+			Updates(map[string]interface{}{"spending_tx_hash": "", "spending_tx_index": 0, "spending_block_height": 0}).Error
+		if err != nil {
+			return err
 		}
 
-		if v.Witness != "" {
-			witnesses := strings.Split(v.Witness, ",")
-			if len(witnesses) == 3 {
-				bs, err := bscript.NewFromHexString(witnesses[1])
-				if err == nil {
-					asm, err := bs.ToASM()
-					if err == nil {
-						scripts := strings.Split(asm, " ")
-						outpoints[i].WitnessAsmScripts = &scripts
-					}
-				}
-			}
+		if err := setIndexerValue(tx, INDEXER_LAST_REORG_HEIGHT_KEY, strconv.Itoa(int(height+1))); err != nil {
+			return err
+		}
+		if err := setIndexerValue(tx, INDEXER_LAST_REORG_DEPTH_KEY, strconv.Itoa(int(depth))); err != nil {
+			return err
 		}
-	}
 
-	return outpoints, nil
+		return d.SetLastHeightWithTx(tx, height)
+	})
 }
 
-func (d *DBRepository) UpdateBlocksAsOrphan(height int32) error {
-	// Update blocks
-	if err := d.Db.Model(&Block{}).Where("height >= ?", height).Update("is_orphan", true).Error; err != nil {
-		return err
+// GetLastReorg returns the first replaced height and the depth of the last reorg, 0 if there was none
+func (d *DBRepository) GetLastReorg() (int32, int32, error) {
+	values := [2]int{}
+	for idx, key := range []string{INDEXER_LAST_REORG_HEIGHT_KEY, INDEXER_LAST_REORG_DEPTH_KEY} {
+		value, err := getIndexerValue(d.Db, key)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return 0, 0, nil
+			}
+
+			return 0, 0, err
+		}
+
+		values[idx], _ = strconv.Atoi(value)
 	}
 
-	return nil
+	return int32(values[0]), int32(values[1]), nil
 }
 
-func (d *DBRepository) GetBlockHeightByHash(height uint64) (*string, error) {
+// GetBlockHashByHeight returns the hash of the canonical block at height, nil if there is none
+func (d *DBRepository) GetBlockHashByHeight(height uint64) (*string, error) {
 	block := Block{}
-	res := d.Db.Where("height = ?", height).First(&block)
+	res := d.Db.Where("height = ? AND is_orphan = ?", height, false).First(&block)
 	if res.Error != nil {
 		if res.Error == gorm.ErrRecordNotFound {
 			return nil, nil
@@ -364,7 +717,7 @@ func (d *DBRepository) GetBlockHeightByHash(height uint64) (*string, error) {
 }
 
 func (d *DBRepository) GetTransactionV1sByBlockHeight(height uint64) ([]*TransactionV1, error) {
-	hash, err := d.GetBlockHeightByHash(height)
+	hash, err := d.GetBlockHashByHeight(height)
 	if err != nil {
 		return nil, err
 	}
@@ -390,6 +743,25 @@ func (d *DBRepository) GetTransactionByHash(hash string) (*Transaction, error) {
 	return &transaction, nil
 }
 
+// GetWitness returns nil if the vin is unknown
+func (d *DBRepository) GetWitness(txHash string, txIndex uint32) (*Witness, error) {
+	vin := Vin{}
+	res := d.Db.Where("tx_hash = ? AND tx_index = ?", txHash, txIndex).First(&vin)
+	if res.Error != nil {
+		if res.Error == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+
+		return nil, res.Error
+	}
+
+	witness := DecodeWitness(vin.Stack(), vin.PkScript)
+	witness.TxHash = vin.TxHash
+	witness.TxIndex = vin.TxIndex
+
+	return witness, nil
+}
+
 func (d *DBRepository) GetTransactionV1s(hash string) ([]*TransactionV1, error) {
 	transactionV1s := []*TransactionV1{}
 	transactions := []*Transaction{}
@@ -456,7 +828,7 @@ func (d *DBRepository) GetTransactionV1s(hash string) ([]*TransactionV1, error)
 }
 
 func (d *DBRepository) GetTransactionV2sByBlockHeight(height uint64) ([]*TransactionV2, error) {
-	hash, err := d.GetBlockHeightByHash(height)
+	hash, err := d.GetBlockHashByHeight(height)
 	if err != nil {
 		return nil, err
 	}
@@ -469,12 +841,18 @@ func (d *DBRepository) GetTransactionV2sByBlockHeight(height uint64) ([]*Transac
 }
 
 func (d *DBRepository) GetTransactionV2s(hash string) ([]*TransactionV2, error) {
-	transactionV2s := []*TransactionV2{}
 	transactions := []*Transaction{}
 
 	if resp := d.Db.Order("block_index asc").Where("block_hash = ? ", hash).Find(&transactions); resp.Error != nil {
 		return nil, resp.Error
 	}
+
+	return d.transactionV2s(transactions, VINS_TABLE, VOUTS_TABLE)
+}
+
+// transactionV2s attaches the vins and vouts of the transactions, read from the confirmed or the mempool tables
+func (d *DBRepository) transactionV2s(transactions []*Transaction, vinsTable string, voutsTable string) ([]*TransactionV2, error) {
+	transactionV2s := []*TransactionV2{}
 	if len(transactions) == 0 {
 		return transactionV2s, nil
 	}
@@ -488,51 +866,16 @@ func (d *DBRepository) GetTransactionV2s(hash string) ([]*TransactionV2, error)
 	vins := []*Vin{}
 	vouts := []*Vout{}
 
-	if resp := d.Db.Order("tx_index asc").Where("tx_hash IN ? ", txHashes).Find(&vins); resp.Error != nil {
+	if resp := d.Db.Table(vinsTable).Order("tx_index asc").Where("tx_hash IN ? ", txHashes).Find(&vins); resp.Error != nil {
 		return nil, resp.Error
 	}
-	if resp := d.Db.Order("tx_index asc").Where("tx_hash IN ? ", txHashes).Find(&vouts); resp.Error != nil {
+	if resp := d.Db.Table(voutsTable).Order("tx_index asc").Where("tx_hash IN ? ", txHashes).Find(&vouts); resp.Error != nil {
 		return nil, resp.Error
 	}
 	vinV2s := map[string][]Vin{}
 	voutV2s := map[string][]Vout{}
 
 	for _, vin := range vins {
-
-		// TODO: refactor
-
-		if vin.SignatureScript != "" {
-			scripts, err := ParseP2shSigHexToAsms(vin.SignatureScript)
-			if err == nil {
-				vin.P2shAsmScripts = scripts
-			}
-		}
-
-		if vin.PkScript != "" {
-			bs, err := bscript.NewFromHexString(vin.PkScript)
-			if err == nil {
-				asm, err := bs.ToASM()
-				if err == nil {
-					scripts := strings.Split(asm, " ")
-					vin.PkAsmScripts = &scripts
-				}
-			}
-
-		}
-
-		if vin.Witness != "" {
-			witnesses := strings.Split(vin.Witness, ",")
-			if len(witnesses) == 3 {
-				bs, err := bscript.NewFromHexString(witnesses[1])
-				if err == nil {
-					asm, err := bs.ToASM()
-					if err == nil {
-						scripts := strings.Split(asm, " ")
-						vin.WitnessAsmScripts = &scripts
-					}
-				}
-			}
-		}
 		vinV2s[vin.TxHash] = append(vinV2s[vin.TxHash], *vin)
 	}
 
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqlRecorder keeps the statements of a dry run DB
+type sqlRecorder struct {
+	logger.Interface
+	statements []string
+}
+
+func (r *sqlRecorder) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	sql, _ := fc()
+	r.statements = append(r.statements, sql)
+}
+
+func dryRunRepository(t *testing.T) (*DBRepository, *sqlRecorder) {
+	recorder := &sqlRecorder{Interface: logger.Discard}
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "root@tcp(localhost:3306)/indexer", SkipInitializeWithVersion: true}), &gorm.Config{
+		DryRun:                 true,
+		DisableAutomaticPing:   true,
+		SkipDefaultTransaction: true,
+		Logger:                 recorder,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewDBRepository(db), recorder
+}
+
+func TestMarkVoutsSpent(t *testing.T) {
+	repo, recorder := dryRunRepository(t)
+
+	vins := []Vin{{TxHash: "spending", TxIndex: 1, BlockHeight: 5, FundingTxHash: "funding", FundingTxIndex: 2}}
+	// coinbase
+	vins = append(vins, Vin{TxHash: "coinbase"})
+	for i := 0; i < VOUT_BATCH_SIZE; i++ {
+		vins = append(vins, Vin{TxHash: "spending", TxIndex: 0, BlockHeight: 5, FundingTxHash: "funding", FundingTxIndex: uint32(100 + i)})
+	}
+
+	if err := repo.MarkVoutsSpent(vins); err != nil {
+		t.Fatal(err)
+	}
+
+	// one update per batch
+	if len(recorder.statements) != 2 {
+		t.Fatalf("Expected 2 updates, got %d", len(recorder.statements))
+	}
+
+	update := recorder.statements[0]
+	if !strings.Contains(update, "WHEN 'funding:2' THEN 'spending'") || !strings.Contains(update, "(tx_hash, tx_index) IN (('funding',2),") {
+		t.Errorf("Update incorrect %s", update)
+	}
+	if strings.Contains(update, "coinbase") {
+		t.Errorf("Coinbase vins should be skipped %s", update)
+	}
+}
+
+func TestGetVoutsByOutpoints(t *testing.T) {
+	repo, recorder := dryRunRepository(t)
+
+	if _, err := repo.GetVoutsByOutpoints([][]interface{}{{"a", uint32(1)}, {"b", uint32(0)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "SELECT * FROM `vouts` WHERE tx_hash IN ('a','b') AND (tx_hash, tx_index) IN (('a',1),('b',0))"
+	if len(recorder.statements) != 1 || recorder.statements[0] != expected {
+		t.Errorf("Query incorrect %v", recorder.statements)
+	}
+}
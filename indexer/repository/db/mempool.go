@@ -0,0 +1,159 @@
+package db
+
+import (
+	"gorm.io/gorm"
+)
+
+const VINS_TABLE = "vins"
+const VOUTS_TABLE = "vouts"
+
+// unconfirmed transactions have the confirmed rows shape, with empty block fields
+const MEMPOOL_TRANSACTIONS_TABLE = "mempool_transactions"
+const MEMPOOL_VINS_TABLE = "mempool_vins"
+const MEMPOOL_VOUTS_TABLE = "mempool_vouts"
+const MEMPOOL_RUNESTONES_TABLE = "mempool_runestones"
+
+const MEMPOOL_BATCH_SIZE = 1024
+
+// migrateMempool creates the mempool tables, they change on every transaction so they are kept out of the Dolt history
+func migrateMempool(db *gorm.DB) error {
+	if err := db.Exec("INSERT IGNORE INTO dolt_ignore VALUES ('mempool_%', true)").Error; err != nil {
+		return err
+	}
+
+	if err := db.Table(MEMPOOL_TRANSACTIONS_TABLE).AutoMigrate(&Transaction{}); err != nil {
+		return err
+	}
+	if err := db.Table(MEMPOOL_VINS_TABLE).AutoMigrate(&Vin{}); err != nil {
+		return err
+	}
+	if err := db.Table(MEMPOOL_VOUTS_TABLE).AutoMigrate(&Vout{}); err != nil {
+		return err
+	}
+
+	return db.Table(MEMPOOL_RUNESTONES_TABLE).AutoMigrate(&Runestone{})
+}
+
+// GetMempoolTransactionHashes returns the txids of the indexed mempool transactions
+func (d *DBRepository) GetMempoolTransactionHashes() ([]string, error) {
+	hashes := []string{}
+	if res := d.Db.Table(MEMPOOL_TRANSACTIONS_TABLE).Pluck("hash", &hashes); res.Error != nil {
+		return nil, res.Error
+	}
+
+	return hashes, nil
+}
+
+// CreateMempoolTransactions stores unconfirmed transactions with their vins, vouts and runestones
+func (d *DBRepository) CreateMempoolTransactions(txs []Transaction, vins []Vin, vouts []Vout, runestones []Runestone) error {
+	if len(txs) == 0 {
+		return nil
+	}
+
+	return d.Db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table(MEMPOOL_TRANSACTIONS_TABLE).CreateInBatches(&txs, MEMPOOL_BATCH_SIZE).Error; err != nil {
+			return err
+		}
+		if len(vins) > 0 {
+			if err := tx.Table(MEMPOOL_VINS_TABLE).CreateInBatches(&vins, MEMPOOL_BATCH_SIZE).Error; err != nil {
+				return err
+			}
+		}
+		if len(vouts) > 0 {
+			if err := tx.Table(MEMPOOL_VOUTS_TABLE).CreateInBatches(&vouts, MEMPOOL_BATCH_SIZE).Error; err != nil {
+				return err
+			}
+		}
+		if len(runestones) > 0 {
+			return tx.Table(MEMPOOL_RUNESTONES_TABLE).CreateInBatches(&runestones, MEMPOOL_BATCH_SIZE).Error
+		}
+
+		return nil
+	})
+}
+
+// DeleteMempoolTransactions removes confirmed, replaced or evicted transactions from the mempool tables
+func (d *DBRepository) DeleteMempoolTransactions(hashes []string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	return d.Db.Transaction(func(tx *gorm.DB) error {
+		for start := 0; start < len(hashes); start += MEMPOOL_BATCH_SIZE {
+			batch := hashes[start:min(start+MEMPOOL_BATCH_SIZE, len(hashes))]
+
+			if err := tx.Table(MEMPOOL_TRANSACTIONS_TABLE).Where("hash IN ?", batch).Delete(&Transaction{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Table(MEMPOOL_VINS_TABLE).Where("tx_hash IN ?", batch).Delete(&Vin{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Table(MEMPOOL_VOUTS_TABLE).Where("tx_hash IN ?", batch).Delete(&Vout{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Table(MEMPOOL_RUNESTONES_TABLE).Where("tx_hash IN ?", batch).Delete(&Runestone{}).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DeleteConfirmedMempoolTransactions removes the mempool transactions mined in the blocks from fromHeight to toHeight
+func (d *DBRepository) DeleteConfirmedMempoolTransactions(fromHeight uint64, toHeight uint64) error {
+	confirmed := d.Db.Model(&Transaction{}).Select("hash").Where("block_height >= ? AND block_height <= ?", fromHeight, toHeight)
+
+	if err := d.Db.Table(MEMPOOL_TRANSACTIONS_TABLE).Where("hash IN (?)", confirmed).Delete(&Transaction{}).Error; err != nil {
+		return err
+	}
+	if err := d.Db.Table(MEMPOOL_VINS_TABLE).Where("tx_hash IN (?)", confirmed).Delete(&Vin{}).Error; err != nil {
+		return err
+	}
+	if err := d.Db.Table(MEMPOOL_VOUTS_TABLE).Where("tx_hash IN (?)", confirmed).Delete(&Vout{}).Error; err != nil {
+		return err
+	}
+
+	return d.Db.Table(MEMPOOL_RUNESTONES_TABLE).Where("tx_hash IN (?)", confirmed).Delete(&Runestone{}).Error
+}
+
+// GetMempoolTransactionV2 returns an unconfirmed transaction, nil if it isn't in the mempool
+func (d *DBRepository) GetMempoolTransactionV2(hash string) (*TransactionV2, error) {
+	transactions := []*Transaction{}
+	if res := d.Db.Table(MEMPOOL_TRANSACTIONS_TABLE).Where("hash = ?", hash).Find(&transactions); res.Error != nil {
+		return nil, res.Error
+	}
+
+	transactionV2s, err := d.transactionV2s(transactions, MEMPOOL_VINS_TABLE, MEMPOOL_VOUTS_TABLE)
+	if err != nil || len(transactionV2s) == 0 {
+		return nil, err
+	}
+
+	return transactionV2s[0], nil
+}
+
+// GetMempoolTransactionV2sByAddress returns the unconfirmed transactions spending from or paying to address
+func (d *DBRepository) GetMempoolTransactionV2sByAddress(address string) ([]*TransactionV2, error) {
+	transactions := []*Transaction{}
+	res := d.Db.Table(MEMPOOL_TRANSACTIONS_TABLE).
+		Where("hash IN (?) OR hash IN (?)",
+			d.Db.Table(MEMPOOL_VINS_TABLE).Select("tx_hash").Where("spender = ?", address),
+			d.Db.Table(MEMPOOL_VOUTS_TABLE).Select("tx_hash").Where("spender = ?", address)).
+		Order("hash asc").
+		Find(&transactions)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	return d.transactionV2s(transactions, MEMPOOL_VINS_TABLE, MEMPOOL_VOUTS_TABLE)
+}
+
+// GetMempoolRunestones returns the runestones of the unconfirmed transactions
+func (d *DBRepository) GetMempoolRunestones() ([]*Runestone, error) {
+	runestones := []*Runestone{}
+	if res := d.Db.Table(MEMPOOL_RUNESTONES_TABLE).Order("tx_hash asc").Find(&runestones); res.Error != nil {
+		return nil, res.Error
+	}
+
+	return runestones, nil
+}
@@ -1,10 +1,16 @@
 package db
 
 import (
+	"eastnode/indexer/runes"
+
 	"gorm.io/gorm"
 )
 
 const INDEXER_LAST_HEIGHT_KEY = "INDEXER_LAST_HEIGHT_KEY"
+const INDEXER_PRUNED_HEIGHT_KEY = "INDEXER_PRUNED_HEIGHT_KEY"
+const INDEXER_LAST_REORG_HEIGHT_KEY = "INDEXER_LAST_REORG_HEIGHT_KEY"
+const INDEXER_LAST_REORG_DEPTH_KEY = "INDEXER_LAST_REORG_DEPTH_KEY"
+const MAX_ADDRESS_HISTORY_LIMIT = 1000
 
 type Indexer struct {
 	gorm.Model
@@ -20,9 +26,9 @@ type P2shAsmScripts struct {
 
 type Block struct {
 	Hash string `gorm:"index:idx_hash" json:"hash"`
-	// TODO: need to add index for height
-	Height   uint64 `json:"height"`
-	IsOrphan bool   `json:"is_orphan"`
+	// every height has one canonical block, and any number of orphans after reorgs
+	Height   uint64 `gorm:"index:idx_height_is_orphan,priority:1" json:"height"`
+	IsOrphan bool   `gorm:"index:idx_height_is_orphan,priority:2" json:"is_orphan"`
 
 	PreviousBlock string `json:"previous_block"`
 	Version       int32  `json:"version"`
@@ -36,13 +42,15 @@ type Transaction struct {
 	Hash     string `gorm:"index:idx_hash;unique" json:"hash"`
 	LockTime uint32 `json:"lock_time"`
 	Version  int32  `json:"version"`
+	// raw serialized transaction, including witness data
+	Hex string `gorm:"type:longtext" json:"hex"`
 	// TODO: what's definition of safe?
 	// - safe utxo: safe to spend
 	Safe bool `json:"safe"`
 
 	BlockID     uint   `json:"block_id"`
 	BlockHash   string `gorm:"index:idx_block_hash_index,hash:true,priority:1" json:"block_hash"`
-	BlockHeight uint64 `json:"block_height"`
+	BlockHeight uint64 `gorm:"index:idx_tx_block_height" json:"block_height"`
 
 	// TODO: need to add index for block_index
 	BlockIndex uint32 `gorm:"index:idx_block_hash_index,hash:true,priority:2" json:"block_index"`
@@ -78,11 +86,13 @@ type Vin struct {
 	TxHash          string `gorm:"index:idx_tx_hash" json:"tx_hash"`
 	TxIndex         uint32 `json:"tx_index"`
 	BlockHash       string `json:"block_hash"`
-	BlockHeight     uint64 `json:"block_height"`
+	BlockHeight     uint64 `gorm:"index:idx_vin_block_height" json:"block_height"`
 	BlockTxIndex    uint32 `json:"block_tx_index"`
 	Sequence        uint32 `json:"sequence"`
 	SignatureScript string `json:"signature_script"`
-	Witness         string `json:"witness"`
+	// comma joined witness items, kept for existing smart indexes
+	Witness      string       `json:"witness"`
+	WitnessStack WitnessStack `json:"witness_stack"`
 
 	FundingTxHash  string `json:"funding_tx_hash"`
 	FundingTxIndex uint32 `json:"funding_tx_index"`
@@ -92,9 +102,11 @@ type Vin struct {
 	Spender  string `json:"spender"`
 	Type     string `json:"type"`
 
-	P2shAsmScripts    *P2shAsmScripts `json:"p2sh_asm_scripts" gorm:"-"`
-	PkAsmScripts      *[]string       `json:"pk_asm_scripts" gorm:"-"`
-	WitnessAsmScripts *[]string       `json:"witness_asm_scripts" gorm:"-"`
+	// parsed at index time, rows with an older AsmVersion are backfilled in the background on startup
+	P2shAsmScripts    *P2shAsmScripts `json:"p2sh_asm_scripts" gorm:"type:json;serializer:json"`
+	PkAsmScripts      *[]string       `json:"pk_asm_scripts" gorm:"type:json;serializer:json"`
+	WitnessAsmScripts *[]string       `json:"witness_asm_scripts" gorm:"type:json;serializer:json"`
+	AsmVersion        uint8           `gorm:"index:idx_asm_version" json:"-"`
 }
 
 type Vout struct {
@@ -106,11 +118,79 @@ type Vout struct {
 
 	PkScript string `json:"pk_script"`
 	Value    int64  `json:"value"`
-	Spender  string `json:"spender"`
-	Type     string `json:"type"`
+	Spender  string `gorm:"index:idx_spender" json:"spender"`
+	// script type, one of the bitcoin.SCRIPT_TYPE_* values
+	Type string `gorm:"index:idx_vout_type" json:"type"`
+
+	// the vin spending this vout, empty while unspent
+	SpendingTxHash      string `json:"spending_tx_hash"`
+	SpendingTxIndex     uint32 `json:"spending_tx_index"`
+	SpendingBlockHeight uint64 `gorm:"index:idx_spending_block_height" json:"spending_block_height"`
 
 	P2shAsmScripts *P2shAsmScripts `json:"p2sh_asm_scripts" gorm:"-"`
-	PkAsmScripts   *[]string       `json:"pk_asm_scripts" gorm:"-"`
+	PkAsmScripts   *[]string       `json:"pk_asm_scripts" gorm:"type:json;serializer:json"`
+	AsmVersion     uint8           `gorm:"index:idx_asm_version" json:"-"`
+}
+
+// AddressHistory is what an address received and sent in a transaction
+type AddressHistory struct {
+	Address      string `gorm:"index:idx_address_height,priority:1" json:"address"`
+	TxHash       string `json:"tx_hash"`
+	BlockHash    string `json:"block_hash"`
+	BlockHeight  uint64 `gorm:"index:idx_address_height,priority:2;index:idx_history_block_height" json:"block_height"`
+	BlockTxIndex uint32 `json:"block_tx_index"`
+	Received     int64  `json:"received"`
+	Sent         int64  `json:"sent"`
+}
+
+// Runestone is the decoded runes message of a transaction
+type Runestone struct {
+	TxHash       string `gorm:"index:idx_runestone_tx_hash" json:"tx_hash"`
+	BlockHash    string `json:"block_hash"`
+	BlockHeight  uint64 `gorm:"index:idx_runestone_block_height" json:"block_height"`
+	BlockTxIndex uint32 `json:"block_tx_index"`
+
+	Edicts   []runes.Edict  `gorm:"type:json;serializer:json" json:"edicts"`
+	Etching  *runes.Etching `gorm:"type:json;serializer:json" json:"etching"`
+	Mint     *runes.RuneId  `gorm:"type:json;serializer:json" json:"mint"`
+	Pointer  *uint32        `json:"pointer"`
+	Cenotaph bool           `json:"cenotaph"`
+	Flaw     string         `json:"flaw"`
+}
+
+// Inscription is an ordinals envelope of a transaction input, binary fields are hex encoded
+type Inscription struct {
+	Id           string `gorm:"index:idx_inscription_id,unique" json:"id"`
+	TxHash       string `json:"tx_hash"`
+	BlockHash    string `json:"block_hash"`
+	BlockHeight  uint64 `gorm:"index:idx_inscription_block_height" json:"block_height"`
+	BlockTxIndex uint32 `json:"block_tx_index"`
+	Input        uint32 `json:"input"`
+	Offset       uint32 `json:"offset"`
+
+	ContentType     string   `json:"content_type"`
+	ContentEncoding string   `json:"content_encoding"`
+	Body            string   `gorm:"type:longtext" json:"body"`
+	Parents         []string `gorm:"type:json;serializer:json" json:"parents"`
+	Delegate        string   `json:"delegate"`
+	Pointer         *uint64  `json:"pointer"`
+	Metaprotocol    string   `json:"metaprotocol"`
+	Metadata        string   `gorm:"type:longtext" json:"metadata"`
+
+	Pushnum               bool `json:"pushnum"`
+	Stutter               bool `json:"stutter"`
+	DuplicateField        bool `json:"duplicate_field"`
+	IncompleteField       bool `json:"incomplete_field"`
+	UnrecognizedEvenField bool `json:"unrecognized_even_field"`
+}
+
+type AddressBalance struct {
+	Address  string `json:"address"`
+	Height   int64  `json:"height"`
+	Received int64  `json:"received"`
+	Sent     int64  `json:"sent"`
+	Balance  int64  `json:"balance"`
+	TxCount  int64  `json:"tx_count"`
 }
 
 type VinV1 struct {
@@ -152,10 +232,36 @@ func NewDB(dialector gorm.Dialector, opts ...gorm.Option) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	err = db.AutoMigrate(&Indexer{}, &Block{}, &Transaction{}, &Vin{}, &Vout{})
+	err = db.AutoMigrate(&Indexer{}, &Block{}, &Transaction{}, &Vin{}, &Vout{}, &AddressHistory{}, &Runestone{}, &Inscription{})
 	if err != nil {
 		panic(err)
 	}
 
+	err = migrateMempool(db)
+	if err != nil {
+		panic(err)
+	}
+
+	// large DBs take a while, reads don't depend on it
+	go backfillAsmScripts(NewDBRepository(db))
+
 	return db, nil
 }
+
+func (v *Vout) ToOutPoint() *OutPoint {
+	return &OutPoint{
+		SpendingTxHash:      v.SpendingTxHash,
+		SpendingTxIndex:     v.SpendingTxIndex,
+		SpendingBlockHeight: v.SpendingBlockHeight,
+		FundingTxHash:       v.TxHash,
+		FundingTxIndex:      v.TxIndex,
+		FundingBlockHash:    v.BlockHash,
+		FundingBlockHeight:  v.BlockHeight,
+		FundingBlockTxIndex: v.BlockTxIndex,
+		PkScript:            v.PkScript,
+		Value:               v.Value,
+		Spender:             v.Spender,
+		Type:                v.Type,
+		PkAsmScripts:        v.PkAsmScripts,
+	}
+}
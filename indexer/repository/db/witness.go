@@ -0,0 +1,128 @@
+package db
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/libsv/go-bt/v2/bscript"
+)
+
+// https://github.com/bitcoin/bips/blob/master/bip-0341.mediawiki#script-validation-rules
+const TAPROOT_ANNEX_TAG = 0x50
+const TAPROOT_LEAF_MASK = 0xfe
+const TAPROOT_LEAF_TAPSCRIPT = 0xc0
+const TAPROOT_CONTROL_BASE_SIZE = 33
+const TAPROOT_CONTROL_NODE_SIZE = 32
+const TAPROOT_CONTROL_MAX_NODES = 128
+
+// WitnessStack is stored as a json array of hex encoded items
+type WitnessStack []string
+
+func (w WitnessStack) Value() (driver.Value, error) {
+	if w == nil {
+		return "[]", nil
+	}
+
+	b, err := json.Marshal(w)
+	return string(b), err
+}
+
+func (w *WitnessStack) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*w = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(v, w)
+	case string:
+		return json.Unmarshal([]byte(v), w)
+	default:
+		return fmt.Errorf("invalid witness stack type %T", value)
+	}
+}
+
+func (WitnessStack) GormDataType() string {
+	return "json"
+}
+
+// Witness is a decoded input witness, tapscript fields are only set for taproot script path spends
+type Witness struct {
+	TxHash       string   `json:"tx_hash"`
+	TxIndex      uint32   `json:"tx_index"`
+	Stack        []string `json:"stack"`
+	Annex        string   `json:"annex"`
+	Tapscript    string   `json:"tapscript"`
+	TapscriptAsm []string `json:"tapscript_asm"`
+	ControlBlock string   `json:"control_block"`
+	LeafVersion  uint8    `json:"leaf_version"`
+}
+
+// Stack returns the witness items, falling back to the comma joined column for rows indexed before witness_stack existed
+func (v *Vin) Stack() []string {
+	if len(v.WitnessStack) > 0 || v.Witness == "" {
+		return v.WitnessStack
+	}
+
+	return strings.Split(v.Witness, ",")
+}
+
+// DecodeWitness splits a witness into annex, tapscript and control block following BIP341.
+// pkScript is the spent output script, if empty a script path spend is detected by the control block shape alone
+func DecodeWitness(stack []string, pkScript string) *Witness {
+	witness := &Witness{Stack: stack}
+	if stack == nil {
+		witness.Stack = []string{}
+	}
+
+	if pkScript != "" && !isTaprootScript(pkScript) {
+		return witness
+	}
+
+	items := stack
+	if len(items) >= 2 && strings.HasPrefix(items[len(items)-1], fmt.Sprintf("%02x", TAPROOT_ANNEX_TAG)) {
+		witness.Annex = items[len(items)-1]
+		items = items[:len(items)-1]
+	}
+
+	// a single item is a key path spend
+	if len(items) < 2 {
+		return witness
+	}
+
+	control, err := hex.DecodeString(items[len(items)-1])
+	if err != nil || !isControlBlock(control) {
+		return witness
+	}
+
+	witness.ControlBlock = items[len(items)-1]
+	witness.LeafVersion = control[0] & TAPROOT_LEAF_MASK
+	witness.Tapscript = items[len(items)-2]
+
+	bs, err := bscript.NewFromHexString(witness.Tapscript)
+	if err == nil {
+		asm, err := bs.ToASM()
+		if err == nil {
+			witness.TapscriptAsm = strings.Split(asm, " ")
+		}
+	}
+
+	return witness
+}
+
+// OP_1 <32 bytes>
+func isTaprootScript(pkScript string) bool {
+	return len(pkScript) == 68 && strings.HasPrefix(pkScript, "5120")
+}
+
+func isControlBlock(control []byte) bool {
+	size := len(control) - TAPROOT_CONTROL_BASE_SIZE
+	if size < 0 || size%TAPROOT_CONTROL_NODE_SIZE != 0 || size/TAPROOT_CONTROL_NODE_SIZE > TAPROOT_CONTROL_MAX_NODES {
+		return false
+	}
+
+	// unknown leaf versions are valid too, but every inscription and rune uses tapscript
+	return control[0]&TAPROOT_LEAF_MASK == TAPROOT_LEAF_TAPSCRIPT
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeWitness(t *testing.T) {
+	taproot := "5120" + strings.Repeat("aa", 32)
+	// OP_FALSE OP_IF "ord" OP_ENDIF
+	tapscript := "0063036f726468"
+	control := "c1" + strings.Repeat("bb", 32)
+	signature := strings.Repeat("cc", 64)
+
+	keyPath := DecodeWitness([]string{signature}, taproot)
+	if keyPath.Tapscript != "" || keyPath.ControlBlock != "" {
+		t.Errorf("Key path spend should not have a tapscript %+v", keyPath)
+	}
+
+	scriptPath := DecodeWitness([]string{signature, tapscript, control}, taproot)
+	if scriptPath.Tapscript != tapscript || scriptPath.ControlBlock != control || scriptPath.LeafVersion != 0xc0 {
+		t.Errorf("Script path spend incorrect %+v", scriptPath)
+	}
+	if strings.Join(scriptPath.TapscriptAsm, " ") != "OP_FALSE OP_IF 6f7264 OP_ENDIF" {
+		t.Errorf("Tapscript asm incorrect %v", scriptPath.TapscriptAsm)
+	}
+
+	// more than 3 items, with an annex
+	annexed := DecodeWitness([]string{signature, signature, tapscript, control + strings.Repeat("dd", 32), "50ee"}, "")
+	if annexed.Annex != "50ee" || annexed.Tapscript != tapscript || len(annexed.Stack) != 5 {
+		t.Errorf("Annexed spend incorrect %+v", annexed)
+	}
+
+	// p2wsh inputs also have 3 items
+	p2wsh := DecodeWitness([]string{"", signature, tapscript}, "0020"+strings.Repeat("aa", 32))
+	if p2wsh.Tapscript != "" {
+		t.Errorf("P2wsh spend should not have a tapscript %+v", p2wsh)
+	}
+
+	empty := DecodeWitness(nil, "")
+	if empty.Stack == nil || empty.Tapscript != "" {
+		t.Errorf("Empty witness incorrect %+v", empty)
+	}
+}
+
+func TestWitnessStack(t *testing.T) {
+	stack := WitnessStack{"", "0102"}
+	value, err := stack.Value()
+	if err != nil || value != `["","0102"]` {
+		t.Fatalf("Value incorrect %v %v", value, err)
+	}
+
+	scanned := WitnessStack{}
+	if err := scanned.Scan([]byte(value.(string))); err != nil {
+		t.Fatal(err)
+	}
+	if len(scanned) != 2 || scanned[0] != "" || scanned[1] != "0102" {
+		t.Errorf("Scan incorrect %v", scanned)
+	}
+
+	legacy := Vin{Witness: "01,02,03"}
+	if len(legacy.Stack()) != 3 {
+		t.Errorf("Legacy witness incorrect %v", legacy.Stack())
+	}
+}
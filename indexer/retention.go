@@ -0,0 +1,85 @@
+package indexer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+const INDEXER_MODE_ARCHIVE = "archive"
+const INDEXER_MODE_PRUNE = "prune"
+
+// bitcoind keeps the last 288 blocks when pruning too
+const DEFAULT_PRUNE_DEPTH = 288
+
+// Retention is how much of the chain the indexer keeps
+type Retention struct {
+	// archive keeps every vin and vout, prune deletes the vins and spent vouts older than PruneDepth blocks
+	Mode       string
+	PruneDepth int32
+
+	// first indexed height when nothing is indexed yet, the prevouts of earlier blocks are unknown
+	StartHeight int32
+}
+
+// RetentionFromEnv reads INDEXER_MODE, INDEXER_PRUNE_DEPTH and INDEXER_START_HEIGHT
+func RetentionFromEnv() (Retention, error) {
+	retention := Retention{Mode: INDEXER_MODE_ARCHIVE, PruneDepth: DEFAULT_PRUNE_DEPTH}
+
+	if os.Getenv("INDEXER_MODE") != "" {
+		retention.Mode = os.Getenv("INDEXER_MODE")
+	}
+	if retention.Mode != INDEXER_MODE_ARCHIVE && retention.Mode != INDEXER_MODE_PRUNE {
+		return retention, fmt.Errorf("invalid INDEXER_MODE %s", retention.Mode)
+	}
+
+	if os.Getenv("INDEXER_PRUNE_DEPTH") != "" {
+		depth, err := strconv.Atoi(os.Getenv("INDEXER_PRUNE_DEPTH"))
+		if err != nil {
+			return retention, fmt.Errorf("invalid INDEXER_PRUNE_DEPTH: %w", err)
+		}
+		retention.PruneDepth = int32(depth)
+	}
+	// pruned data can't be restored by a reorg rollback
+	if retention.PruneDepth < REORG_DEPTH_CHECK {
+		return retention, fmt.Errorf("INDEXER_PRUNE_DEPTH must be at least %d", REORG_DEPTH_CHECK)
+	}
+
+	if os.Getenv("INDEXER_START_HEIGHT") != "" {
+		height, err := strconv.Atoi(os.Getenv("INDEXER_START_HEIGHT"))
+		if err != nil {
+			return retention, fmt.Errorf("invalid INDEXER_START_HEIGHT: %w", err)
+		}
+		if height < 0 {
+			return retention, fmt.Errorf("invalid INDEXER_START_HEIGHT %d", height)
+		}
+		retention.StartHeight = int32(height)
+	}
+
+	return retention, nil
+}
+
+// pruneHeight is the height up to which the data can be pruned once height is indexed, -1 if nothing can be
+func (r Retention) pruneHeight(height int32) int32 {
+	if r.Mode != INDEXER_MODE_PRUNE || height-r.PruneDepth < 0 {
+		return -1
+	}
+
+	return height - r.PruneDepth
+}
+
+// prune deletes the data older than the prune depth after height is flushed
+func (i *Indexer) prune(height int32) error {
+	pruneHeight := i.retention.pruneHeight(height)
+	if pruneHeight < 0 {
+		return nil
+	}
+
+	if err := i.DbRepo.PruneToHeight(pruneHeight); err != nil {
+		return fmt.Errorf("failed to prune to height %d: %w", pruneHeight, err)
+	}
+
+	log.Printf("Pruned blocks to height %d", pruneHeight)
+	return nil
+}
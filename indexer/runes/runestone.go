@@ -0,0 +1,498 @@
+package runes
+
+import (
+	"eastnode/indexer/repository/bitcoin"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"unicode/utf8"
+)
+
+// https://docs.ordinals.com/runes/specification.html
+const OP_RETURN = 0x6a
+const MAGIC_NUMBER = 0x5d // OP_13
+
+const MAX_DIVISIBILITY = 38
+const MAX_SPACERS = 0b00000111_11111111_11111111_11111111
+
+const TAG_BODY = 0
+const TAG_DIVISIBILITY = 1
+const TAG_FLAGS = 2
+const TAG_SPACERS = 3
+const TAG_RUNE = 4
+const TAG_SYMBOL = 5
+const TAG_PREMINE = 6
+const TAG_CAP = 8
+const TAG_AMOUNT = 10
+const TAG_HEIGHT_START = 12
+const TAG_HEIGHT_END = 14
+const TAG_OFFSET_START = 16
+const TAG_OFFSET_END = 18
+const TAG_MINT = 20
+const TAG_POINTER = 22
+
+const FLAG_ETCHING = 0
+const FLAG_TERMS = 1
+const FLAG_TURBO = 2
+
+const FLAW_EDICT_OUTPUT = "edict_output"
+const FLAW_EDICT_RUNE_ID = "edict_rune_id"
+const FLAW_INVALID_SCRIPT = "invalid_script"
+const FLAW_OPCODE = "opcode"
+const FLAW_SUPPLY_OVERFLOW = "supply_overflow"
+const FLAW_TRAILING_INTEGERS = "trailing_integers"
+const FLAW_TRUNCATED_FIELD = "truncated_field"
+const FLAW_UNRECOGNIZED_EVEN_TAG = "unrecognized_even_tag"
+const FLAW_UNRECOGNIZED_FLAG = "unrecognized_flag"
+const FLAW_VARINT = "varint"
+
+var maxU128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+type RuneId struct {
+	Block uint64 `json:"block"`
+	Tx    uint32 `json:"tx"`
+}
+
+func (id RuneId) String() string {
+	return fmt.Sprintf("%d:%d", id.Block, id.Tx)
+}
+
+// next applies an edict's delta encoded rune id
+func (id RuneId) next(block *big.Int, tx *big.Int) (RuneId, bool) {
+	if !block.IsUint64() || !isUint32(tx) || id.Block+block.Uint64() < id.Block {
+		return RuneId{}, false
+	}
+
+	next := RuneId{Block: id.Block + block.Uint64(), Tx: uint32(tx.Uint64())}
+	if block.Sign() == 0 {
+		if uint64(id.Tx)+tx.Uint64() > math.MaxUint32 {
+			return RuneId{}, false
+		}
+		next.Tx = id.Tx + uint32(tx.Uint64())
+	}
+
+	if next.Block == 0 && next.Tx > 0 {
+		return RuneId{}, false
+	}
+
+	return next, true
+}
+
+// amounts are u128, encoded as decimal strings
+type Edict struct {
+	Id     RuneId `json:"id"`
+	Amount string `json:"amount"`
+	Output uint32 `json:"output"`
+}
+
+type Terms struct {
+	Amount      *string `json:"amount"`
+	Cap         *string `json:"cap"`
+	HeightStart *uint64 `json:"height_start"`
+	HeightEnd   *uint64 `json:"height_end"`
+	OffsetStart *uint64 `json:"offset_start"`
+	OffsetEnd   *uint64 `json:"offset_end"`
+}
+
+type Etching struct {
+	Divisibility *uint8  `json:"divisibility"`
+	Premine      *string `json:"premine"`
+	Rune         *string `json:"rune"`
+	SpacedRune   *string `json:"spaced_rune"`
+	Spacers      *uint32 `json:"spacers"`
+	Symbol       *string `json:"symbol"`
+	Terms        *Terms  `json:"terms"`
+	Turbo        bool    `json:"turbo"`
+}
+
+// Runestone is a decoded runes message. A cenotaph only keeps the etched rune and the mint,
+// its edicts are dropped and the runes of the tx's inputs are burned.
+type Runestone struct {
+	Edicts   []Edict  `json:"edicts"`
+	Etching  *Etching `json:"etching"`
+	Mint     *RuneId  `json:"mint"`
+	Pointer  *uint32  `json:"pointer"`
+	Cenotaph bool     `json:"cenotaph"`
+	Flaw     string   `json:"flaw"`
+}
+
+type field struct {
+	tag    *big.Int
+	values []*big.Int
+}
+
+type message struct {
+	flaw   string
+	edicts []Edict
+	fields map[string]*field
+}
+
+func (m *message) setFlaw(flaw string) {
+	if m.flaw == "" {
+		m.flaw = flaw
+	}
+}
+
+// take consumes n values of a tag if with accepts them, rejected values stay in the fields
+func (m *message) take(tag int64, n int, with func(values []*big.Int) bool) {
+	key := fmt.Sprint(tag)
+	f, ok := m.fields[key]
+	if !ok || len(f.values) < n || !with(f.values[:n]) {
+		return
+	}
+
+	f.values = f.values[n:]
+	if len(f.values) == 0 {
+		delete(m.fields, key)
+	}
+}
+
+// Decipher decodes the runestone of a tx, it returns nil if the tx has no runestone
+func Decipher(tx *bitcoin.Tx) *Runestone {
+	payload, flaw, ok := runestonePayload(tx)
+	if !ok {
+		return nil
+	}
+	if flaw != "" {
+		return &Runestone{Edicts: []Edict{}, Cenotaph: true, Flaw: flaw}
+	}
+
+	integers, err := decodeIntegers(payload)
+	if err != nil {
+		return &Runestone{Edicts: []Edict{}, Cenotaph: true, Flaw: FLAW_VARINT}
+	}
+
+	m := newMessage(tx, integers)
+
+	flags := new(big.Int)
+	m.take(TAG_FLAGS, 1, func(values []*big.Int) bool {
+		flags.Set(values[0])
+		return true
+	})
+
+	var etching *Etching
+	premine := new(big.Int)
+	supply := new(big.Int)
+	if takeFlag(flags, FLAG_ETCHING) {
+		etching = &Etching{}
+
+		m.take(TAG_DIVISIBILITY, 1, func(values []*big.Int) bool {
+			if !values[0].IsUint64() || values[0].Uint64() > MAX_DIVISIBILITY {
+				return false
+			}
+			divisibility := uint8(values[0].Uint64())
+			etching.Divisibility = &divisibility
+			return true
+		})
+		m.take(TAG_PREMINE, 1, func(values []*big.Int) bool {
+			premine.Set(values[0])
+			etching.Premine = bigString(values[0])
+			return true
+		})
+		m.take(TAG_RUNE, 1, func(values []*big.Int) bool {
+			name := RuneName(values[0])
+			etching.Rune = &name
+			return true
+		})
+		m.take(TAG_SPACERS, 1, func(values []*big.Int) bool {
+			if !values[0].IsUint64() || values[0].Uint64() > MAX_SPACERS {
+				return false
+			}
+			spacers := uint32(values[0].Uint64())
+			etching.Spacers = &spacers
+			return true
+		})
+		m.take(TAG_SYMBOL, 1, func(values []*big.Int) bool {
+			if !isUint32(values[0]) || !utf8.ValidRune(rune(values[0].Uint64())) {
+				return false
+			}
+			symbol := string(rune(values[0].Uint64()))
+			etching.Symbol = &symbol
+			return true
+		})
+
+		if takeFlag(flags, FLAG_TERMS) {
+			terms := &Terms{}
+			amount := new(big.Int)
+			capacity := new(big.Int)
+
+			m.take(TAG_CAP, 1, func(values []*big.Int) bool {
+				capacity.Set(values[0])
+				terms.Cap = bigString(values[0])
+				return true
+			})
+			m.take(TAG_HEIGHT_START, 1, takeUint64(&terms.HeightStart))
+			m.take(TAG_HEIGHT_END, 1, takeUint64(&terms.HeightEnd))
+			m.take(TAG_AMOUNT, 1, func(values []*big.Int) bool {
+				amount.Set(values[0])
+				terms.Amount = bigString(values[0])
+				return true
+			})
+			m.take(TAG_OFFSET_START, 1, takeUint64(&terms.OffsetStart))
+			m.take(TAG_OFFSET_END, 1, takeUint64(&terms.OffsetEnd))
+
+			etching.Terms = terms
+			supply.Mul(capacity, amount)
+		}
+
+		etching.Turbo = takeFlag(flags, FLAG_TURBO)
+
+		if etching.Rune != nil {
+			spacers := uint32(0)
+			if etching.Spacers != nil {
+				spacers = *etching.Spacers
+			}
+			spacedRune := SpacedRune(*etching.Rune, spacers)
+			etching.SpacedRune = &spacedRune
+		}
+	}
+
+	var mint *RuneId
+	m.take(TAG_MINT, 2, func(values []*big.Int) bool {
+		if !values[0].IsUint64() || !isUint32(values[1]) {
+			return false
+		}
+		id := RuneId{Block: values[0].Uint64(), Tx: uint32(values[1].Uint64())}
+		if id.Block == 0 && id.Tx > 0 {
+			return false
+		}
+		mint = &id
+		return true
+	})
+
+	var pointer *uint32
+	m.take(TAG_POINTER, 1, func(values []*big.Int) bool {
+		if !isUint32(values[0]) || values[0].Uint64() >= uint64(len(tx.Vout)) {
+			return false
+		}
+		p := uint32(values[0].Uint64())
+		pointer = &p
+		return true
+	})
+
+	if etching != nil && supply.Add(supply, premine).Cmp(maxU128) > 0 {
+		m.setFlaw(FLAW_SUPPLY_OVERFLOW)
+	}
+
+	if flags.Sign() != 0 {
+		m.setFlaw(FLAW_UNRECOGNIZED_FLAG)
+	}
+
+	for _, f := range m.fields {
+		if f.tag.Bit(0) == 0 {
+			m.setFlaw(FLAW_UNRECOGNIZED_EVEN_TAG)
+		}
+	}
+
+	if m.flaw != "" {
+		cenotaph := &Runestone{Edicts: []Edict{}, Mint: mint, Cenotaph: true, Flaw: m.flaw}
+		if etching != nil && etching.Rune != nil {
+			cenotaph.Etching = &Etching{Rune: etching.Rune}
+		}
+
+		return cenotaph
+	}
+
+	return &Runestone{Edicts: m.edicts, Etching: etching, Mint: mint, Pointer: pointer}
+}
+
+// runestonePayload concatenates the data pushes of the first OP_RETURN OP_13 output
+func runestonePayload(tx *bitcoin.Tx) ([]byte, string, bool) {
+	prefix := fmt.Sprintf("%02x%02x", OP_RETURN, MAGIC_NUMBER)
+
+	for _, vout := range tx.Vout {
+		if !strings.HasPrefix(vout.ScriptPubKey.Hex, prefix) {
+			continue
+		}
+
+		script, err := hex.DecodeString(vout.ScriptPubKey.Hex)
+		if err != nil {
+			return nil, FLAW_INVALID_SCRIPT, true
+		}
+
+		payload := []byte{}
+		for i := 2; i < len(script); {
+			op := script[i]
+			i++
+
+			var size uint64
+			switch {
+			case op <= 0x4b:
+				size = uint64(op)
+			case op == 0x4c && i+1 <= len(script):
+				size = uint64(script[i])
+				i += 1
+			case op == 0x4d && i+2 <= len(script):
+				size = uint64(binary.LittleEndian.Uint16(script[i:]))
+				i += 2
+			case op == 0x4e && i+4 <= len(script):
+				size = uint64(binary.LittleEndian.Uint32(script[i:]))
+				i += 4
+			case op >= 0x4c && op <= 0x4e:
+				return nil, FLAW_INVALID_SCRIPT, true
+			default:
+				return nil, FLAW_OPCODE, true
+			}
+
+			if uint64(len(script)-i) < size {
+				return nil, FLAW_INVALID_SCRIPT, true
+			}
+			payload = append(payload, script[i:i+int(size)]...)
+			i += int(size)
+		}
+
+		return payload, "", true
+	}
+
+	return nil, "", false
+}
+
+// decodeIntegers decodes the LEB128 u128 integers of a payload
+func decodeIntegers(payload []byte) ([]*big.Int, error) {
+	integers := []*big.Int{}
+
+	for i := 0; i < len(payload); {
+		n := new(big.Int)
+		terminated := false
+
+		for j := 0; i < len(payload); j++ {
+			b := payload[i]
+			i++
+
+			if j > 18 {
+				return nil, fmt.Errorf("overlong varint")
+			}
+			value := uint64(b & 0x7f)
+			if j == 18 && value&0x7c != 0 {
+				return nil, fmt.Errorf("varint overflow")
+			}
+			n.Or(n, new(big.Int).Lsh(new(big.Int).SetUint64(value), uint(7*j)))
+
+			if b&0x80 == 0 {
+				terminated = true
+				break
+			}
+		}
+
+		if !terminated {
+			return nil, fmt.Errorf("unterminated varint")
+		}
+		integers = append(integers, n)
+	}
+
+	return integers, nil
+}
+
+func newMessage(tx *bitcoin.Tx, integers []*big.Int) *message {
+	m := &message{edicts: []Edict{}, fields: map[string]*field{}}
+
+	for i := 0; i < len(integers); i += 2 {
+		tag := integers[i]
+
+		if tag.Cmp(big.NewInt(TAG_BODY)) == 0 {
+			id := RuneId{}
+			body := integers[i+1:]
+
+			for j := 0; j < len(body); j += 4 {
+				if len(body)-j < 4 {
+					m.setFlaw(FLAW_TRAILING_INTEGERS)
+					break
+				}
+
+				next, ok := id.next(body[j], body[j+1])
+				if !ok {
+					m.setFlaw(FLAW_EDICT_RUNE_ID)
+					break
+				}
+
+				// the output after the last one splits the amount between the non OP_RETURN outputs
+				output := body[j+3]
+				if !isUint32(output) || output.Uint64() > uint64(len(tx.Vout)) {
+					m.setFlaw(FLAW_EDICT_OUTPUT)
+					break
+				}
+
+				m.edicts = append(m.edicts, Edict{Id: next, Amount: body[j+2].String(), Output: uint32(output.Uint64())})
+				id = next
+			}
+			break
+		}
+
+		if i+1 >= len(integers) {
+			m.setFlaw(FLAW_TRUNCATED_FIELD)
+			break
+		}
+
+		key := tag.String()
+		if _, ok := m.fields[key]; !ok {
+			m.fields[key] = &field{tag: tag}
+		}
+		m.fields[key].values = append(m.fields[key].values, integers[i+1])
+	}
+
+	return m
+}
+
+// RuneName is the modified base-26 name of a rune, A is 0 and AA is 26
+func RuneName(n *big.Int) string {
+	if n.Cmp(maxU128) == 0 {
+		return "BCGDENLQRQWDSLRUGSNLBTMFIJAV"
+	}
+
+	v := new(big.Int).Add(n, big.NewInt(1))
+	mod := new(big.Int)
+	name := []byte{}
+	for v.Sign() > 0 {
+		v.Sub(v, big.NewInt(1))
+		v.DivMod(v, big.NewInt(26), mod)
+		name = append(name, byte('A'+mod.Int64()))
+	}
+
+	for i, j := 0, len(name)-1; i < j; i, j = i+1, j-1 {
+		name[i], name[j] = name[j], name[i]
+	}
+
+	return string(name)
+}
+
+// SpacedRune inserts a • after every letter whose spacers bit is set
+func SpacedRune(name string, spacers uint32) string {
+	spaced := strings.Builder{}
+	for i, c := range name {
+		spaced.WriteRune(c)
+		if i < len(name)-1 && spacers&(1<<i) != 0 {
+			spaced.WriteString("•")
+		}
+	}
+
+	return spaced.String()
+}
+
+func takeFlag(flags *big.Int, flag int) bool {
+	set := flags.Bit(flag) == 1
+	flags.SetBit(flags, flag, 0)
+	return set
+}
+
+func takeUint64(result **uint64) func(values []*big.Int) bool {
+	return func(values []*big.Int) bool {
+		if !values[0].IsUint64() {
+			return false
+		}
+		value := values[0].Uint64()
+		*result = &value
+		return true
+	}
+}
+
+func isUint32(n *big.Int) bool {
+	return n.IsUint64() && n.Uint64() <= math.MaxUint32
+}
+
+func bigString(n *big.Int) *string {
+	s := n.String()
+	return &s
+}
@@ -0,0 +1,142 @@
+package runes
+
+import (
+	"eastnode/indexer/repository/bitcoin"
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+func encodeVarint(n uint64) []byte {
+	b := []byte{}
+	for n >= 0x80 {
+		b = append(b, byte(n&0x7f)|0x80)
+		n >>= 7
+	}
+	return append(b, byte(n))
+}
+
+// runestoneTx builds a tx with a runestone of the integers in a single push, followed by a p2wpkh output
+func runestoneTx(integers ...uint64) *bitcoin.Tx {
+	payload := []byte{}
+	for _, n := range integers {
+		payload = append(payload, encodeVarint(n)...)
+	}
+	script := append([]byte{OP_RETURN, MAGIC_NUMBER, byte(len(payload))}, payload...)
+
+	return scriptTx(hex.EncodeToString(script))
+}
+
+func scriptTx(scripts ...string) *bitcoin.Tx {
+	tx := &bitcoin.Tx{}
+	for _, script := range append(scripts, "0014"+"0000000000000000000000000000000000000000") {
+		tx.Vout = append(tx.Vout, bitcoin.Vout{ScriptPubKey: bitcoin.ScriptPubKey{Hex: script}})
+	}
+	return tx
+}
+
+func TestDecipher_Etching(t *testing.T) {
+	runestone := Decipher(runestoneTx(
+		TAG_FLAGS, 0b11,
+		TAG_RUNE, 18955, // ABAB
+		TAG_SPACERS, 0b101,
+		TAG_DIVISIBILITY, 2,
+		TAG_SYMBOL, '$',
+		TAG_PREMINE, 1000,
+		TAG_CAP, 10,
+		TAG_AMOUNT, 100,
+		TAG_HEIGHT_END, 840000,
+		TAG_POINTER, 1,
+		TAG_BODY,
+		840000, 1, 50, 1,
+		0, 2, 25, 2,
+	))
+
+	if runestone == nil || runestone.Cenotaph {
+		t.Fatalf("Runestone incorrect %+v", runestone)
+	}
+
+	etching := runestone.Etching
+	if etching == nil || *etching.Rune != "ABAB" || *etching.SpacedRune != "A•BA•B" || *etching.Divisibility != 2 || *etching.Symbol != "$" || *etching.Premine != "1000" || etching.Turbo {
+		t.Fatalf("Etching incorrect %+v", etching)
+	}
+
+	if etching.Terms == nil || *etching.Terms.Cap != "10" || *etching.Terms.Amount != "100" || *etching.Terms.HeightEnd != 840000 || etching.Terms.HeightStart != nil {
+		t.Errorf("Terms incorrect %+v", etching.Terms)
+	}
+
+	if runestone.Pointer == nil || *runestone.Pointer != 1 {
+		t.Errorf("Pointer incorrect %v", runestone.Pointer)
+	}
+
+	// the second edict's id is delta encoded from the first
+	expected := []Edict{
+		{Id: RuneId{840000, 1}, Amount: "50", Output: 1},
+		{Id: RuneId{840000, 3}, Amount: "25", Output: 2},
+	}
+	if len(runestone.Edicts) != len(expected) {
+		t.Fatalf("Edicts incorrect %+v", runestone.Edicts)
+	}
+	for i := range expected {
+		if runestone.Edicts[i] != expected[i] {
+			t.Errorf("Edict %d incorrect %+v", i, runestone.Edicts[i])
+		}
+	}
+}
+
+func TestDecipher_Cenotaph(t *testing.T) {
+	tests := []struct {
+		name string
+		tx   *bitcoin.Tx
+		flaw string
+	}{
+		{"unrecognized even tag", runestoneTx(126, 0), FLAW_UNRECOGNIZED_EVEN_TAG},
+		{"unrecognized flag", runestoneTx(TAG_FLAGS, 1<<3), FLAW_UNRECOGNIZED_FLAG},
+		{"trailing integers", runestoneTx(TAG_BODY, 1, 1, 1), FLAW_TRAILING_INTEGERS},
+		{"truncated field", runestoneTx(TAG_FLAGS), FLAW_TRUNCATED_FIELD},
+		{"edict output", runestoneTx(TAG_BODY, 1, 1, 1, 3), FLAW_EDICT_OUTPUT},
+		{"edict rune id", runestoneTx(TAG_BODY, 0, 1, 1, 0), FLAW_EDICT_RUNE_ID},
+		{"invalid mint", runestoneTx(TAG_MINT, 0, TAG_MINT, 1), FLAW_UNRECOGNIZED_EVEN_TAG},
+		{"opcode", scriptTx("6a5d51"), FLAW_OPCODE},
+		{"invalid script", scriptTx("6a5d4c"), FLAW_INVALID_SCRIPT},
+		{"varint", scriptTx("6a5d0180"), FLAW_VARINT},
+	}
+
+	for _, test := range tests {
+		runestone := Decipher(test.tx)
+		if runestone == nil || !runestone.Cenotaph || runestone.Flaw != test.flaw || len(runestone.Edicts) != 0 {
+			t.Errorf("%s: cenotaph incorrect %+v", test.name, runestone)
+		}
+	}
+
+	// odd tags are ignored, the etched rune and mint are kept
+	runestone := Decipher(runestoneTx(TAG_FLAGS, 1, TAG_RUNE, 0, TAG_MINT, 1, TAG_MINT, 0, 127, 5, 126, 0))
+	if !runestone.Cenotaph || *runestone.Etching.Rune != "A" || *runestone.Mint != (RuneId{1, 0}) {
+		t.Errorf("Cenotaph incorrect %+v", runestone)
+	}
+}
+
+func TestDecipher_NoRunestone(t *testing.T) {
+	if runestone := Decipher(scriptTx("6a0401020304")); runestone != nil {
+		t.Errorf("Expected no runestone, got %+v", runestone)
+	}
+
+	// the first runestone output is used, even if a later one is valid
+	runestone := Decipher(scriptTx("6a5d4f", "6a5d00"))
+	if runestone == nil || runestone.Flaw != FLAW_OPCODE {
+		t.Errorf("Runestone incorrect %+v", runestone)
+	}
+}
+
+func TestRuneName(t *testing.T) {
+	tests := map[uint64]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"}
+	for n, name := range tests {
+		if RuneName(new(big.Int).SetUint64(n)) != name {
+			t.Errorf("Rune name of %d incorrect %s", n, RuneName(new(big.Int).SetUint64(n)))
+		}
+	}
+
+	if RuneName(maxU128) != "BCGDENLQRQWDSLRUGSNLBTMFIJAV" {
+		t.Errorf("Max rune name incorrect %s", RuneName(maxU128))
+	}
+}
@@ -1,42 +1,116 @@
 package indexer
 
 import (
+	"eastnode/indexer/repository/bitcoin"
+	"errors"
+	"log"
+	"os"
 	"time"
 )
 
 type Scheduler struct {
 	indexer *Indexer
+
+	// new block hashes from bitcoind zmq, nil when polling only
+	blockNotifications <-chan string
 }
 
 const REORG_DEPTH_CHECK = 6
 
+// polling interval when synced, also the fallback when zmq notifications are missed
+const POLL_INTERVAL = 15 * time.Second
+
+const RETRY_DELAY = 1 * time.Second
+const MAX_RETRY_DELAY = 1 * time.Minute
+
 func NewScheduler(indexer *Indexer) *Scheduler {
-	return &Scheduler{indexer}
+	var blockNotifications <-chan string
+	if os.Getenv("BTC_ZMQ_URL") != "" {
+		blockNotifications = bitcoin.SubscribeHashBlock(os.Getenv("BTC_ZMQ_URL"))
+	}
+
+	return &Scheduler{indexer, blockNotifications}
 }
 
 func (s *Scheduler) Start() {
+	retryDelay := RETRY_DELAY
+
 	for {
-		indexerLastHeight, err := s.indexer.DbRepo.GetLastHeight()
-		if err != nil {
-			panic(err)
+		err := s.sync()
+		if err == nil {
+			retryDelay = RETRY_DELAY
+			continue
 		}
-		bitcoinLastHeight, err := s.indexer.bitcoinRepo.GetBlockCount()
-		if err != nil {
-			panic(err)
+
+		switch {
+		case bitcoin.IsWarmingUp(err):
+			log.Printf("Bitcoin node is warming up: %s", err)
+			time.Sleep(RETRY_DELAY * 5)
+		case errors.Is(err, bitcoin.ErrUnauthorized):
+			log.Println(err)
+			time.Sleep(MAX_RETRY_DELAY)
+		case errors.Is(err, ErrReorgBelowPruned):
+			log.Panicf("Can't recover from the reorg, resync the indexer from an empty DB: %s", err)
+		case bitcoin.IsBlockNotFound(err):
+			// the bitcoin chain changed while syncing, the reorg is handled by the next sync
+			log.Printf("Block not found, retrying: %s", err)
+			time.Sleep(RETRY_DELAY)
+		default:
+			log.Printf("Sync failed, retrying in %s: %s", retryDelay, err)
+			time.Sleep(retryDelay)
+			retryDelay = min(MAX_RETRY_DELAY, retryDelay*2)
 		}
+	}
+}
 
-		if indexerLastHeight == bitcoinLastHeight {
-			// if fully synced, continue
-			time.Sleep(15 * time.Second)
-			continue
-		} else {
-			// if not fully synced, sync blocks
-			err := s.indexer.SyncBlocks(indexerLastHeight+1, bitcoinLastHeight)
-			if err != nil {
-				panic(err)
-			}
+func (s *Scheduler) sync() error {
+	indexerLastHeight, err := s.indexer.DbRepo.GetLastHeight()
+	if err != nil {
+		return err
+	}
+	bitcoinLastHeight, err := s.indexer.bitcoinRepo.GetBlockCount()
+	if err != nil {
+		return err
+	}
+
+	// nothing indexed yet, start from the configured height
+	if indexerLastHeight < 0 {
+		indexerLastHeight = s.indexer.retention.StartHeight - 1
+	}
+	s.indexer.Status.setHeights(indexerLastHeight, bitcoinLastHeight)
+
+	if indexerLastHeight > bitcoinLastHeight {
+		// the start height isn't mined yet
+		s.waitForBlock()
+		return nil
+	}
+
+	if indexerLastHeight == bitcoinLastHeight {
+		// a reorg to a chain of the same height only changes the tip hash
+		reorged, err := s.indexer.CheckTip(indexerLastHeight)
+		if err != nil || reorged {
+			return err
 		}
 
-		time.Sleep(1 * time.Second)
+		// if fully synced, wait for the next block
+		s.waitForBlock()
+		return nil
+	}
+
+	// if not fully synced, sync blocks
+	err = s.indexer.SyncBlocks(indexerLastHeight+1, bitcoinLastHeight)
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(1 * time.Second)
+	return nil
+}
+
+func (s *Scheduler) waitForBlock() {
+	select {
+	case hash := <-s.blockNotifications:
+		log.Printf("New block %s", hash)
+	case <-time.After(POLL_INTERVAL):
 	}
 }
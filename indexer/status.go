@@ -0,0 +1,234 @@
+package indexer
+
+import (
+	"eastnode/indexer/repository/bitcoin"
+	"eastnode/indexer/repository/db"
+	"log"
+	"sync"
+	"time"
+)
+
+const SYNC_MODE_BULK = "bulk"
+const SYNC_MODE_TIP = "tip"
+
+// blocks per second are measured over the blocks flushed in this window
+const SYNC_RATE_WINDOW = 10 * time.Minute
+
+// refresh interval of the status of an indexer running in another process
+const STATUS_POLL_INTERVAL = 10 * time.Second
+
+// SyncStatus is how far the indexer is behind the bitcoin node
+type SyncStatus struct {
+	LastHeight      int32   `json:"last_height"`
+	LastHash        string  `json:"last_hash"`
+	BitcoinHeight   int32   `json:"bitcoin_height"`
+	BlocksPerSecond float64 `json:"blocks_per_second"`
+	// seconds until synced at the current rate, -1 if unknown
+	EtaSeconds      int64  `json:"eta_seconds"`
+	LastReorgHeight int32  `json:"last_reorg_height"`
+	LastReorgDepth  int32  `json:"last_reorg_depth"`
+	Mode            string `json:"mode"`
+	Synced          bool   `json:"synced"`
+}
+
+type heightSample struct {
+	at     time.Time
+	height int32
+}
+
+// Status tracks the sync progress, it's written by the scheduler and read by the RPC server
+type Status struct {
+	mu sync.RWMutex
+
+	lastHeight    int32
+	lastHash      string
+	bitcoinHeight int32
+	mode          string
+
+	lastReorgHeight int32
+	lastReorgDepth  int32
+
+	// flushed heights within SYNC_RATE_WINDOW
+	samples []heightSample
+}
+
+func NewStatus() *Status {
+	return &Status{lastHeight: -1, bitcoinHeight: -1, mode: SYNC_MODE_TIP}
+}
+
+func (s *Status) setHeights(lastHeight int32, bitcoinHeight int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastHeight = lastHeight
+	s.bitcoinHeight = bitcoinHeight
+	s.mode = syncMode(lastHeight, bitcoinHeight)
+}
+
+func syncMode(lastHeight int32, bitcoinHeight int32) string {
+	if bitcoinHeight-lastHeight > MAX_BLOCK_FLUSH {
+		return SYNC_MODE_BULK
+	}
+
+	return SYNC_MODE_TIP
+}
+
+// indexed records a flush, for the sync rate
+func (s *Status) indexed(height int32, hash string, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastHeight = height
+	s.lastHash = hash
+	s.samples = append(s.samples, heightSample{at, height})
+
+	expired := 0
+	for expired < len(s.samples) && at.Sub(s.samples[expired].at) > SYNC_RATE_WINDOW {
+		expired++
+	}
+	s.samples = s.samples[expired:]
+}
+
+// reorged records a rollback of depth blocks, the blocks from height are indexed again
+func (s *Status) reorged(height int32, depth int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastHeight = height - 1
+	s.lastHash = ""
+	s.lastReorgHeight = height
+	s.lastReorgDepth = depth
+	// the rate across a rollback is meaningless
+	s.samples = nil
+}
+
+// polled records the heights read from the DB of an indexer running in another process
+func (s *Status) polled(lastHeight int32, lastHash string, bitcoinHeight int32, lastReorgHeight int32, lastReorgDepth int32, at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// a rollback, the rate across it is meaningless
+	if lastHeight < s.lastHeight {
+		s.samples = nil
+	}
+	if lastHeight != s.lastHeight && lastHeight >= 0 {
+		s.samples = append(s.samples, heightSample{at, lastHeight})
+	}
+
+	expired := 0
+	for expired < len(s.samples) && at.Sub(s.samples[expired].at) > SYNC_RATE_WINDOW {
+		expired++
+	}
+	s.samples = s.samples[expired:]
+
+	s.lastHeight = lastHeight
+	s.lastHash = lastHash
+	s.bitcoinHeight = bitcoinHeight
+	s.mode = syncMode(lastHeight, bitcoinHeight)
+	s.lastReorgHeight = lastReorgHeight
+	s.lastReorgDepth = lastReorgDepth
+}
+
+// WatchStatus follows an indexer running in another process, it reads the last indexed block
+// and the last reorg from the DB and the tip from the bitcoin node every interval
+func WatchStatus(dbRepo *db.DBRepository, bitcoinRepo bitcoin.BitcoinRepositoryInterface, interval time.Duration) *Status {
+	status := NewStatus()
+
+	if err := refreshStatus(status, dbRepo, bitcoinRepo); err != nil {
+		log.Printf("Failed to read the indexer status: %s", err)
+	}
+
+	go func() {
+		for {
+			time.Sleep(interval)
+
+			if err := refreshStatus(status, dbRepo, bitcoinRepo); err != nil {
+				log.Printf("Failed to read the indexer status: %s", err)
+			}
+		}
+	}()
+
+	return status
+}
+
+func refreshStatus(status *Status, dbRepo *db.DBRepository, bitcoinRepo bitcoin.BitcoinRepositoryInterface) error {
+	lastHeight, err := dbRepo.GetLastHeight()
+	if err != nil {
+		return err
+	}
+
+	lastHash := ""
+	if lastHeight >= 0 {
+		hash, err := dbRepo.GetBlockHashByHeight(uint64(lastHeight))
+		if err != nil {
+			return err
+		}
+		if hash != nil {
+			lastHash = *hash
+		}
+	}
+
+	// stored by Indexer.Reorg
+	lastReorgHeight, lastReorgDepth, err := dbRepo.GetLastReorg()
+	if err != nil {
+		return err
+	}
+
+	bitcoinHeight, err := bitcoinRepo.GetBlockCount()
+	if err != nil {
+		return err
+	}
+
+	status.polled(lastHeight, lastHash, bitcoinHeight, lastReorgHeight, lastReorgDepth, time.Now())
+	return nil
+}
+
+// Synced returns true once the indexer reached the bitcoin tip, false if the status is unknown
+func (s *Status) Synced() bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.bitcoinHeight >= 0 && s.lastHeight >= s.bitcoinHeight
+}
+
+// Get returns the status at now, LastHash is empty until the first flush after startup or a reorg
+func (s *Status) Get(now time.Time) SyncStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	status := SyncStatus{
+		LastHeight:      s.lastHeight,
+		LastHash:        s.lastHash,
+		BitcoinHeight:   s.bitcoinHeight,
+		EtaSeconds:      -1,
+		LastReorgHeight: s.lastReorgHeight,
+		LastReorgDepth:  s.lastReorgDepth,
+		Mode:            s.mode,
+		Synced:          s.bitcoinHeight >= 0 && s.lastHeight >= s.bitcoinHeight,
+	}
+
+	// the window ends now, so a stalled indexer slows down to 0
+	recent := 0
+	for recent < len(s.samples) && now.Sub(s.samples[recent].at) > SYNC_RATE_WINDOW {
+		recent++
+	}
+	if samples := s.samples[recent:]; len(samples) >= 2 {
+		first, last := samples[0], samples[len(samples)-1]
+		if seconds := now.Sub(first.at).Seconds(); seconds > 0 {
+			status.BlocksPerSecond = float64(last.height-first.height) / seconds
+		}
+	}
+
+	switch {
+	case status.Synced:
+		status.EtaSeconds = 0
+	case status.BlocksPerSecond > 0:
+		status.EtaSeconds = int64(float64(status.BitcoinHeight-status.LastHeight) / status.BlocksPerSecond)
+	}
+
+	return status
+}
@@ -3,6 +3,7 @@ package jsonrpc
 import (
 	"eastnode/indexer/repository/bitcoin"
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
@@ -33,6 +34,17 @@ func (s *BitcoinServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Forward the RPC call to the Bitcoin node
 	jsonRes, err := s.BitcoinRepo.ForwardRPC(request.Method, params)
+	rpcErr := &bitcoin.RPCError{}
+	if errors.As(err, &rpcErr) {
+		// pass bitcoind errors through with their code
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			JsonRPC string            `json:"jsonrpc"`
+			Error   *bitcoin.RPCError `json:"error"`
+			ID      interface{}       `json:"id"`
+		}{"2.0", rpcErr, request.ID})
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -2,6 +2,7 @@ package jsonrpc
 
 import (
 	"eastnode/chain"
+	"eastnode/indexer"
 	"eastnode/types"
 	"eastnode/utils"
 	"encoding/hex"
@@ -12,6 +13,8 @@ import (
 
 type CommonServer struct {
 	Chain *chain.Chain
+	// nil when the indexer runs in another process
+	IndexerStatus *indexer.Status
 }
 
 func (s *CommonServer) Query(r *http.Request, params *string, reply *types.RpcReply) error {
@@ -29,9 +32,23 @@ func (s *CommonServer) Query(r *http.Request, params *string, reply *types.RpcRe
 		nonce := s.Chain.GetNonce(pubKey)
 
 		*reply = types.RpcReply{
-			BlockHash:   blockHash,
-			BlockHeight: blockHeight,
-			Result:      utils.Itob(nonce),
+			BlockHash:     blockHash,
+			BlockHeight:   blockHeight,
+			IndexerSynced: s.IndexerStatus.Synced(),
+			Result:        utils.Itob(nonce),
+		}
+	} else if queryParams.FunctionName == "get_balance" {
+		account := queryParams.Args[0]
+		balance, err := s.Chain.GetBalance(account)
+		if err != nil {
+			return err
+		}
+
+		*reply = types.RpcReply{
+			BlockHash:     blockHash,
+			BlockHeight:   blockHeight,
+			IndexerSynced: s.IndexerStatus.Synced(),
+			Result:        utils.Itob(balance),
 		}
 	}
 
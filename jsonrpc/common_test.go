@@ -0,0 +1,109 @@
+package jsonrpc
+
+import (
+	"crypto/sha256"
+	"eastnode/chain"
+	"eastnode/types"
+	"eastnode/utils"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/dustinxie/ecc"
+)
+
+func signTransaction(t *testing.T, privateKey *btcec.PrivateKey, transaction types.Transaction) types.SignedTransaction {
+	packed := utils.BorshSerializeAndEncodeHex(transaction)
+	packedBytes, _ := hex.DecodeString(packed)
+	hash := sha256.Sum256(packedBytes)
+
+	signature, err := ecc.SignBytes(privateKey.ToECDSA(), hash[:], ecc.Normal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signatureHex := hex.EncodeToString(signature)
+
+	return types.SignedTransaction{
+		ID:          utils.SHA256([]byte(signatureHex)),
+		Signature:   signatureHex,
+		Transaction: packed,
+	}
+}
+
+func queryBalance(t *testing.T, server *CommonServer, account string) uint64 {
+	params := utils.BorshSerializeAndEncodeHex(types.CommonServerQuery{FunctionName: "get_balance", Args: []string{account}})
+
+	reply := new(types.RpcReply)
+	if err := server.Query(nil, &params, reply); err != nil {
+		t.Fatal(err)
+	}
+
+	return utils.Btoi(reply.Result)
+}
+
+func TestTransferFee(t *testing.T) {
+	t.Setenv("TX_FEE", "10")
+
+	privateKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer := hex.EncodeToString(privateKey.PubKey().SerializeCompressed())
+
+	bc := chain.NewFakeChain([]chain.GenesisAccount{{Account: signer, Value: "1000"}})
+	defer t.Cleanup(chain.ClearFakeChain)
+
+	receiverKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	receiver := hex.EncodeToString(receiverKey.PubKey().SerializeCompressed())
+
+	actions := utils.BorshSerializeAndEncodeHex([]types.Action{{Kind: "transfer", Args: []string{"100"}}})
+	signedTx := signTransaction(t, privateKey, types.Transaction{
+		Nonce:    1,
+		Signer:   signer,
+		Receiver: receiver,
+		Actions:  actions,
+		ChainID:  chain.GetChainID(),
+	})
+
+	// the mutation produces the block
+	runtimeServer := &RuntimeServer{Chain: bc}
+	params := utils.BorshSerializeAndEncodeHex(signedTx)
+	reply := new(types.RpcReply)
+	if err := runtimeServer.Mutate(nil, &params, reply); err != nil {
+		t.Fatal(err)
+	}
+	if string(reply.Result) != signedTx.ID {
+		t.Fatalf("Transaction rejected %s", reply.Result)
+	}
+	if bc.GetBlockHeight() != 1 {
+		t.Errorf("Block not produced %d", bc.GetBlockHeight())
+	}
+
+	commonServer := &CommonServer{Chain: bc}
+	if balance := queryBalance(t, commonServer, signer); balance != 890 {
+		t.Errorf("Signer balance incorrect %d", balance)
+	}
+	if balance := queryBalance(t, commonServer, receiver); balance != 100 {
+		t.Errorf("Receiver balance incorrect %d", balance)
+	}
+
+	// the receiver has no balance for the fee
+	signedTx = signTransaction(t, receiverKey, types.Transaction{
+		Nonce:    1,
+		Signer:   receiver,
+		Receiver: signer,
+		Actions:  actions,
+		ChainID:  chain.GetChainID(),
+	})
+	t.Setenv("TX_FEE", "101")
+	params = utils.BorshSerializeAndEncodeHex(signedTx)
+	if err := runtimeServer.Mutate(nil, &params, reply); err != nil {
+		t.Fatal(err)
+	}
+	if string(reply.Result) == signedTx.ID {
+		t.Error("Transaction without balance for the fee should be rejected")
+	}
+}
@@ -0,0 +1,112 @@
+package jsonrpc
+
+import (
+	"eastnode/indexer"
+	indexerDb "eastnode/indexer/repository/db"
+	"eastnode/types"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type IndexerServer struct {
+	DbRepo  *indexerDb.DBRepository
+	Indexer *indexer.Indexer
+	// status of an indexer running in another process, used when Indexer is nil
+	IndexerStatus *indexer.Status
+}
+
+func (s *IndexerServer) GetAddressHistory(r *http.Request, args *types.AddressHistoryArgs, reply *[]*indexerDb.AddressHistory) error {
+	history, err := s.DbRepo.GetAddressHistory(args.Address, args.Offset, args.Limit)
+	if err != nil {
+		return err
+	}
+
+	*reply = history
+	return nil
+}
+
+func (s *IndexerServer) GetAddressBalance(r *http.Request, args *types.AddressBalanceArgs, reply *indexerDb.AddressBalance) error {
+	balance, err := s.DbRepo.GetAddressBalance(args.Address, args.Height)
+	if err != nil {
+		return err
+	}
+
+	*reply = *balance
+	return nil
+}
+
+func (s *IndexerServer) GetInscription(r *http.Request, args *types.InscriptionArgs, reply *indexerDb.Inscription) error {
+	inscription, err := s.DbRepo.GetInscription(args.Id)
+	if err != nil {
+		return err
+	}
+	if inscription == nil {
+		return fmt.Errorf("inscription %s not found", args.Id)
+	}
+
+	*reply = *inscription
+	return nil
+}
+
+func (s *IndexerServer) GetInscriptionsByBlockHeight(r *http.Request, args *types.InscriptionsByBlockHeightArgs, reply *[]*indexerDb.Inscription) error {
+	inscriptions, err := s.DbRepo.GetInscriptionsByBlockHeight(args.Height)
+	if err != nil {
+		return err
+	}
+
+	*reply = inscriptions
+	return nil
+}
+
+func (s *IndexerServer) GetMempoolTransaction(r *http.Request, args *types.MempoolTransactionArgs, reply *indexerDb.TransactionV2) error {
+	transaction, err := s.DbRepo.GetMempoolTransactionV2(args.Hash)
+	if err != nil {
+		return err
+	}
+	if transaction == nil {
+		return fmt.Errorf("transaction %s not in mempool", args.Hash)
+	}
+
+	*reply = *transaction
+	return nil
+}
+
+func (s *IndexerServer) GetMempoolTransactionsByAddress(r *http.Request, args *types.MempoolTransactionsByAddressArgs, reply *[]*indexerDb.TransactionV2) error {
+	transactions, err := s.DbRepo.GetMempoolTransactionV2sByAddress(args.Address)
+	if err != nil {
+		return err
+	}
+
+	*reply = transactions
+	return nil
+}
+
+func (s *IndexerServer) GetMempoolRunestones(r *http.Request, args *types.MempoolRunestonesArgs, reply *[]*indexerDb.Runestone) error {
+	runestones, err := s.DbRepo.GetMempoolRunestones()
+	if err != nil {
+		return err
+	}
+
+	*reply = runestones
+	return nil
+}
+
+func (s *IndexerServer) GetSyncStatus(r *http.Request, args *types.SyncStatusArgs, reply *indexer.SyncStatus) error {
+	if s.Indexer == nil {
+		if s.IndexerStatus == nil {
+			return fmt.Errorf("indexer is not running in this node")
+		}
+
+		*reply = s.IndexerStatus.Get(time.Now())
+		return nil
+	}
+
+	status, err := s.Indexer.SyncStatus()
+	if err != nil {
+		return err
+	}
+
+	*reply = *status
+	return nil
+}
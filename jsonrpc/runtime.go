@@ -2,6 +2,7 @@ package jsonrpc
 
 import (
 	"eastnode/chain"
+	"eastnode/indexer"
 	"eastnode/types"
 	"eastnode/utils"
 	"encoding/hex"
@@ -12,6 +13,8 @@ import (
 
 type RuntimeServer struct {
 	Chain *chain.Chain
+	// nil when the indexer runs in another process
+	IndexerStatus *indexer.Status
 }
 
 func (s *RuntimeServer) Mutate(r *http.Request, params *string, reply *types.RpcReply) error {
@@ -35,15 +38,17 @@ func (s *RuntimeServer) Mutate(r *http.Request, params *string, reply *types.Rpc
 		s.Chain.ProduceBlock()
 
 		*reply = types.RpcReply{
-			BlockHash:   blockHash,
-			BlockHeight: blockHeight,
-			Result:      []byte(newSignedTx.ID),
+			BlockHash:     blockHash,
+			BlockHeight:   blockHeight,
+			IndexerSynced: s.IndexerStatus.Synced(),
+			Result:        []byte(newSignedTx.ID),
 		}
 	} else {
 		*reply = types.RpcReply{
-			BlockHash:   blockHash,
-			BlockHeight: blockHeight,
-			Result:      []byte(err.Error()),
+			BlockHash:     blockHash,
+			BlockHeight:   blockHeight,
+			IndexerSynced: s.IndexerStatus.Synced(),
+			Result:        []byte(err.Error()),
 		}
 	}
 
@@ -62,9 +67,10 @@ func (s *RuntimeServer) Query(r *http.Request, params *types.RuntimeServerQuery,
 		smartIndexWasm := s.Chain.GetSmartIndexWasm(smartIndexAddress)
 
 		*reply = types.ServerQueryReply{
-			BlockHash:   blockHash,
-			BlockHeight: blockHeight,
-			Result:      hex.EncodeToString(smartIndexWasm),
+			BlockHash:     blockHash,
+			BlockHeight:   blockHeight,
+			IndexerSynced: s.IndexerStatus.Synced(),
+			Result:        hex.EncodeToString(smartIndexWasm),
 		}
 
 	} else if params.FunctionName == "view_function" {
@@ -74,18 +80,20 @@ func (s *RuntimeServer) Query(r *http.Request, params *types.RuntimeServerQuery,
 
 		if err != nil {
 			*reply = types.ServerQueryReply{
-				BlockHash:   blockHash,
-				BlockHeight: blockHeight,
-				Result:      hex.EncodeToString([]byte(err.Error())),
+				BlockHash:     blockHash,
+				BlockHeight:   blockHeight,
+				IndexerSynced: s.IndexerStatus.Synced(),
+				Result:        hex.EncodeToString([]byte(err.Error())),
 			}
 
 		} else {
 			result, _ := json.Marshal(res)
 
 			*reply = types.ServerQueryReply{
-				BlockHash:   blockHash,
-				BlockHeight: blockHeight,
-				Result:      hex.EncodeToString(result),
+				BlockHash:     blockHash,
+				BlockHeight:   blockHeight,
+				IndexerSynced: s.IndexerStatus.Synced(),
+				Result:        hex.EncodeToString(result),
 			}
 		}
 
@@ -95,27 +103,30 @@ func (s *RuntimeServer) Query(r *http.Request, params *types.RuntimeServerQuery,
 		result, _ := json.Marshal(s.Chain.GetTransaction(txId))
 
 		*reply = types.ServerQueryReply{
-			BlockHash:   blockHash,
-			BlockHeight: blockHeight,
-			Result:      hex.EncodeToString(result),
+			BlockHash:     blockHash,
+			BlockHeight:   blockHeight,
+			IndexerSynced: s.IndexerStatus.Synced(),
+			Result:        hex.EncodeToString(result),
 		}
 	} else if params.FunctionName == "select_native_sql" {
 		res, err := s.Chain.WasmRuntime.RunSelectFunction(params.Args[0], params.Args[1:])
 
 		if err != nil {
 			*reply = types.ServerQueryReply{
-				BlockHash:   blockHash,
-				BlockHeight: blockHeight,
-				Result:      hex.EncodeToString([]byte(err.Error())),
+				BlockHash:     blockHash,
+				BlockHeight:   blockHeight,
+				IndexerSynced: s.IndexerStatus.Synced(),
+				Result:        hex.EncodeToString([]byte(err.Error())),
 			}
 
 		} else {
 			result, _ := json.Marshal(res)
 
 			*reply = types.ServerQueryReply{
-				BlockHash:   blockHash,
-				BlockHeight: blockHeight,
-				Result:      hex.EncodeToString(result),
+				BlockHash:     blockHash,
+				BlockHeight:   blockHeight,
+				IndexerSynced: s.IndexerStatus.Synced(),
+				Result:        hex.EncodeToString(result),
 			}
 		}
 
@@ -0,0 +1,84 @@
+package runtime
+
+import (
+	"context"
+	"eastnode/types"
+	"eastnode/utils"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// Exported function returning the manifest json of a smart index
+const MANIFEST_FUNCTION = "manifest"
+
+// Manifest lists the functions callable by transactions (Call) and queries (View),
+// Owner functions must also be in Call and need the smart index owners approval.
+// Smart indexes without a manifest export allow every function, but calls need the owners approval.
+// BlockHook is called as BlockHook(height, height) for every indexed bitcoin block
+// from BlockHookStartHeight on, as part of block production.
+type Manifest struct {
+	Call  []string `json:"call"`
+	View  []string `json:"view"`
+	Owner []string `json:"owner"`
+
+	BlockHook            string `json:"block_hook"`
+	BlockHookStartHeight int64  `json:"block_hook_start_height"`
+}
+
+func (m *Manifest) Allows(functionName string, kind types.ActionKind) bool {
+	if m == nil {
+		return true
+	}
+
+	if kind == types.View {
+		return slices.Contains(m.View, functionName)
+	}
+
+	return slices.Contains(m.Call, functionName)
+}
+
+func (m *Manifest) IsOwnerOnly(functionName string) bool {
+	// any export could change the smart index state, e.g. init
+	if m == nil {
+		return true
+	}
+
+	return slices.Contains(m.Owner, functionName)
+}
+
+// GetManifest runs the manifest export of wasmBytes, results are cached by wasm hash
+func (r *WasmRuntime) GetManifest(wasmBytes []byte, smartIndexAddress string) (*Manifest, error) {
+	wasmHash := utils.SHA256(wasmBytes)
+	if manifest, ok := r.manifests.Load(wasmHash); ok {
+		return manifest.(*Manifest), nil
+	}
+
+	ctx := context.Background()
+	wazeroRuntime := wazero.NewRuntime(ctx)
+	defer wazeroRuntime.Close(ctx)
+
+	compiled, err := wazeroRuntime.CompileModule(ctx, wasmBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest *Manifest
+	if _, ok := compiled.ExportedFunctions()[MANIFEST_FUNCTION]; ok {
+		output, err := r.RunWasmFunction("", wasmBytes, smartIndexAddress, MANIFEST_FUNCTION, []string{}, types.View)
+		if err != nil {
+			return nil, err
+		}
+
+		manifest = new(Manifest)
+		if err := json.Unmarshal([]byte(output.(string)), manifest); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+	}
+
+	r.manifests.Store(wasmHash, manifest)
+
+	return manifest, nil
+}
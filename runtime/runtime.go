@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"sync"
 	"unicode/utf16"
 
 	"github.com/tetratelabs/wazero"
@@ -28,6 +29,8 @@ type WasmRuntime struct {
 	Store         store.Store
 	Mod           api.Module
 	IndexerDbRepo *indexerDb.DBRepository
+
+	manifests sync.Map
 }
 
 // ref: https://github.com/RPG-18/wasmer-go-assemblyscript/blob/main/assemblyscript/go.ts
@@ -83,6 +86,16 @@ func (r *WasmRuntime) writeString(memory api.Memory, str string) uint32 {
 	return uint32(stringOffset)
 }
 
+// writeResult writes the JSON of an indexer query result for the host functions, a failed query panics
+func (r *WasmRuntime) writeResult(result any, err error) uint32 {
+	if err != nil {
+		panic(err)
+	}
+	serializedResult, _ := json.Marshal(result)
+
+	return r.writeString(r.Mod.Memory(), string(serializedResult))
+}
+
 func (r *WasmRuntime) loadWasm(wasmBytes []byte, ctx context.Context, smartIndexAddress string, signer Address, kind types.ActionKind, output *string, errorMessage *error) api.Module {
 	wazeroRuntime := wazero.NewRuntime(ctx)
 
@@ -190,70 +203,36 @@ func (r *WasmRuntime) loadWasm(wasmBytes []byte, ctx context.Context, smartIndex
 		Export("selectNative").
 		NewFunctionBuilder().
 		WithFunc(func(height int64) uint32 {
-			result, err := r.IndexerDbRepo.GetBlockByHeight(height)
-			if err != nil {
-				panic(err)
-			}
-			serializedResult, _ := json.Marshal(result)
-
-			ptr := r.writeString(r.Mod.Memory(), string(serializedResult))
-
-			return uint32(ptr)
+			return r.writeResult(r.IndexerDbRepo.GetBlockByHeight(height))
 		}).
 		Export("getBlockByHeight").
 		NewFunctionBuilder().
+		WithFunc(func(hashPtr uint32) uint32 {
+			hash := ToString(r.Mod.Memory(), hashPtr)
+
+			return r.writeResult(r.IndexerDbRepo.GetBlockByHash(hash))
+		}).
+		Export("getBlockByHash").
+		NewFunctionBuilder().
 		WithFunc(func(blockHash uint32) uint32 {
 			blockHashStr := ToString(r.Mod.Memory(), blockHash)
-			result, err := r.IndexerDbRepo.GetTransactionsByBlockHash(blockHashStr)
-			if err != nil {
-				panic(err)
-			}
-
-			serializedResult, _ := json.Marshal(result)
-
-			ptr := r.writeString(r.Mod.Memory(), string(serializedResult))
-
-			return uint32(ptr)
+			return r.writeResult(r.IndexerDbRepo.GetTransactionsByBlockHash(blockHashStr))
 		}).
 		Export("getTransactionsByBlockHash").
 		NewFunctionBuilder().
 		WithFunc(func(transactionHash uint32) uint32 {
 			transactionHashStr := ToString(r.Mod.Memory(), transactionHash)
-			result, err := r.IndexerDbRepo.GetOutpointsByTransactionHash(transactionHashStr)
-			if err != nil {
-				panic(err)
-			}
-			serializedResult, _ := json.Marshal(result)
-
-			ptr := r.writeString(r.Mod.Memory(), string(serializedResult))
-
-			return uint32(ptr)
+			return r.writeResult(r.IndexerDbRepo.GetOutpointsByTransactionHash(transactionHashStr))
 		}).
 		Export("getOutpointsByTransactionHash").
 		NewFunctionBuilder().
 		WithFunc(func(height uint64) uint32 {
-			result, err := r.IndexerDbRepo.GetTransactionV1sByBlockHeight(height)
-			if err != nil {
-				panic(err)
-			}
-			serializedResult, _ := json.Marshal(result)
-
-			ptr := r.writeString(r.Mod.Memory(), string(serializedResult))
-
-			return uint32(ptr)
+			return r.writeResult(r.IndexerDbRepo.GetTransactionV1sByBlockHeight(height))
 		}).
 		Export("getTransactionV1sByBlockHeight").
 		NewFunctionBuilder().
 		WithFunc(func(height uint64) uint32 {
-			result, err := r.IndexerDbRepo.GetTransactionV2sByBlockHeight(height)
-			if err != nil {
-				panic(err)
-			}
-			serializedResult, _ := json.Marshal(result)
-
-			ptr := r.writeString(r.Mod.Memory(), string(serializedResult))
-
-			return uint32(ptr)
+			return r.writeResult(r.IndexerDbRepo.GetTransactionV2sByBlockHeight(height))
 		}).
 		Export("getTransactionV2sByBlockHeight").
 		NewFunctionBuilder().
@@ -271,6 +250,13 @@ func (r *WasmRuntime) loadWasm(wasmBytes []byte, ctx context.Context, smartIndex
 		}).
 		Export("contractAddress").
 		NewFunctionBuilder().
+		WithFunc(func() uint32 {
+			ptr := r.writeString(r.Mod.Memory(), string(signer))
+
+			return uint32(ptr)
+		}).
+		Export("caller").
+		NewFunctionBuilder().
 		WithFunc(func(strPtr uint32) {
 			str := ToString(r.Mod.Memory(), strPtr)
 
@@ -298,32 +284,99 @@ func (r *WasmRuntime) loadWasm(wasmBytes []byte, ctx context.Context, smartIndex
 		Export("getNetwork").
 		NewFunctionBuilder().
 		WithFunc(func() uint32 {
-			result, err := r.IndexerDbRepo.GetLastHeight()
-			if err != nil {
-				panic(err)
-			}
-			serializedResult, _ := json.Marshal(result)
-
-			ptr := r.writeString(r.Mod.Memory(), string(serializedResult))
-
-			return uint32(ptr)
+			return r.writeResult(r.IndexerDbRepo.GetLastHeight())
 		}).
 		Export("getLastHeight").
 		NewFunctionBuilder().
 		WithFunc(func(hashPtr uint32) uint32 {
 			hash := ToString(r.Mod.Memory(), hashPtr)
 
-			result, err := r.IndexerDbRepo.GetTransactionByHash(hash)
-			if err != nil {
-				panic(err)
+			return r.writeResult(r.IndexerDbRepo.GetTransactionByHash(hash))
+		}).
+		Export("getTransactionByHash").
+		NewFunctionBuilder().
+		WithFunc(func(addressPtr uint32) uint32 {
+			address := ToString(r.Mod.Memory(), addressPtr)
+
+			return r.writeResult(r.IndexerDbRepo.GetUnspentOutpointsByAddress(address))
+		}).
+		Export("getUtxosByAddress").
+		NewFunctionBuilder().
+		WithFunc(func(hashPtr uint32, index uint32) uint32 {
+			hash := ToString(r.Mod.Memory(), hashPtr)
+
+			return r.writeResult(r.IndexerDbRepo.GetUnspentOutpoint(hash, index))
+		}).
+		Export("getUtxoByOutpoint").
+		NewFunctionBuilder().
+		WithFunc(func(addressPtr uint32, offset uint32, limit uint32) uint32 {
+			address := ToString(r.Mod.Memory(), addressPtr)
+
+			return r.writeResult(r.IndexerDbRepo.GetAddressHistory(address, int(offset), int(limit)))
+		}).
+		Export("getAddressHistory").
+		NewFunctionBuilder().
+		WithFunc(func(addressPtr uint32, height int64) uint32 {
+			address := ToString(r.Mod.Memory(), addressPtr)
+
+			return r.writeResult(r.IndexerDbRepo.GetAddressBalance(address, height))
+		}).
+		Export("getAddressBalance").
+		NewFunctionBuilder().
+		WithFunc(func(hashPtr uint32, index uint32) uint32 {
+			hash := ToString(r.Mod.Memory(), hashPtr)
+
+			return r.writeResult(r.IndexerDbRepo.GetWitness(hash, index))
+		}).
+		Export("getWitness").
+		NewFunctionBuilder().
+		WithFunc(func(height uint64) uint32 {
+			return r.writeResult(r.IndexerDbRepo.GetRunestonesByBlockHeight(height))
+		}).
+		Export("getRunestonesByBlockHeight").
+		NewFunctionBuilder().
+		WithFunc(func(idPtr uint32) uint32 {
+			id := ToString(r.Mod.Memory(), idPtr)
+
+			return r.writeResult(r.IndexerDbRepo.GetInscription(id))
+		}).
+		Export("getInscriptionById").
+		NewFunctionBuilder().
+		WithFunc(func(height uint64) uint32 {
+			return r.writeResult(r.IndexerDbRepo.GetInscriptionsByBlockHeight(height))
+		}).
+		Export("getInscriptionsByBlockHeight").
+		NewFunctionBuilder().
+		WithFunc(func(hashPtr uint32) uint32 {
+			if kind != types.View {
+				log.Panicln("Cannot read the mempool outside of a view")
+				return 0
 			}
-			serializedResult, _ := json.Marshal(result)
+			hash := ToString(r.Mod.Memory(), hashPtr)
 
-			ptr := r.writeString(r.Mod.Memory(), string(serializedResult))
+			return r.writeResult(r.IndexerDbRepo.GetMempoolTransactionV2(hash))
+		}).
+		Export("getMempoolTransactionV2").
+		NewFunctionBuilder().
+		WithFunc(func(addressPtr uint32) uint32 {
+			if kind != types.View {
+				log.Panicln("Cannot read the mempool outside of a view")
+				return 0
+			}
+			address := ToString(r.Mod.Memory(), addressPtr)
 
-			return uint32(ptr)
+			return r.writeResult(r.IndexerDbRepo.GetMempoolTransactionV2sByAddress(address))
+		}).
+		Export("getMempoolTransactionV2sByAddress").
+		NewFunctionBuilder().
+		WithFunc(func() uint32 {
+			if kind != types.View {
+				log.Panicln("Cannot read the mempool outside of a view")
+				return 0
+			}
+			return r.writeResult(r.IndexerDbRepo.GetMempoolRunestones())
 		}).
-		Export("getTransactionByHash")
+		Export("getMempoolRunestones")
 
 	assemblyscript.NewFunctionExporter().
 		ExportFunctions(envBuilder)
@@ -80,3 +80,27 @@ func TestRunGetContractAddress(t *testing.T) {
 		t.Errorf("Output incorrect: %s", output)
 	}
 }
+
+func TestManifest(t *testing.T) {
+	var legacy *Manifest
+	if !legacy.Allows("init", types.Call) || !legacy.Allows("getBalance", types.View) {
+		t.Error("Smart index without manifest should allow every function")
+	}
+	if !legacy.IsOwnerOnly("init") || !legacy.IsOwnerOnly("index") {
+		t.Error("Smart index without manifest should only be called by its owners")
+	}
+
+	manifest := &Manifest{Call: []string{"init", "index"}, View: []string{"getBalance"}, Owner: []string{"init"}}
+
+	if !manifest.Allows("index", types.Call) || manifest.Allows("index", types.View) {
+		t.Error("index should only be callable as call")
+	}
+
+	if !manifest.Allows("getBalance", types.View) || manifest.Allows("getBalance", types.Call) {
+		t.Error("getBalance should only be callable as view")
+	}
+
+	if !manifest.IsOwnerOnly("init") || manifest.IsOwnerOnly("index") {
+		t.Error("only init should be owner only")
+	}
+}
@@ -28,6 +28,9 @@ export declare function selectNative(statement: string, args: string): i32;
 @external("env", "getBlockByHeight")
 export declare function getBlockByHeight(height: u64): i32;
 
+@external("env", "getBlockByHash")
+export declare function envGetBlockByHash(hash: string): i32;
+
 @external("env", "getTransactionsByBlockHash")
 export declare function getTransactionsByBlockHash(block_hash: string): i32;
 
@@ -52,3 +55,39 @@ export declare function envGetTransactionByHash(hash: string): i32;
 
 @external("env", "getTransactionV2sByBlockHeight")
 export declare function envGetTransactionV2sByBlockHeight(height: u64): i32;
+
+@external("env", "caller")
+export declare function envCaller(): i32;
+
+@external("env", "getUtxosByAddress")
+export declare function envGetUtxosByAddress(address: string): i32;
+
+@external("env", "getUtxoByOutpoint")
+export declare function envGetUtxoByOutpoint(hash: string, index: u32): i32;
+
+@external("env", "getAddressHistory")
+export declare function envGetAddressHistory(address: string, offset: u32, limit: u32): i32;
+
+@external("env", "getAddressBalance")
+export declare function envGetAddressBalance(address: string, height: i64): i32;
+
+@external("env", "getWitness")
+export declare function envGetWitness(hash: string, index: u32): i32;
+
+@external("env", "getRunestonesByBlockHeight")
+export declare function envGetRunestonesByBlockHeight(height: u64): i32;
+
+@external("env", "getInscriptionById")
+export declare function envGetInscriptionById(id: string): i32;
+
+@external("env", "getInscriptionsByBlockHeight")
+export declare function envGetInscriptionsByBlockHeight(height: u64): i32;
+
+@external("env", "getMempoolTransactionV2")
+export declare function envGetMempoolTransactionV2(hash: string): i32;
+
+@external("env", "getMempoolTransactionV2sByAddress")
+export declare function envGetMempoolTransactionV2sByAddress(address: string): i32;
+
+@external("env", "getMempoolRunestones")
+export declare function envGetMempoolRunestones(): i32;
@@ -16,8 +16,22 @@ import {
   deleteRows,
   getTxUTXOByBlockHeight,
   getUTXOByTransactionHash,
+  getUTXOsByAddress,
+  getUTXOByOutpoint,
+  getAddressHistory,
+  getAddressBalance,
+  getWitness,
+  getRunestonesByBlockHeight,
+  getInscriptionById,
+  getInscriptionsByBlockHeight,
+  getBlockByHash,
+  getMempoolTransactionV2,
+  getMempoolTransactionV2sByAddress,
+  getMempoolRunestones,
   getTxsByBlockHeight,
-  getContractAddress
+  getContractAddress,
+  getCaller,
+  Manifest
 } from "./sdk";
 export {
   consoleLog,
@@ -37,8 +51,22 @@ export {
   deleteRows,
   getTxUTXOByBlockHeight,
   getUTXOByTransactionHash,
+  getUTXOsByAddress,
+  getUTXOByOutpoint,
+  getAddressHistory,
+  getAddressBalance,
+  getWitness,
+  getRunestonesByBlockHeight,
+  getInscriptionById,
+  getInscriptionsByBlockHeight,
+  getBlockByHash,
+  getMempoolTransactionV2,
+  getMempoolTransactionV2sByAddress,
+  getMempoolRunestones,
   selectNative,
   JSON,
   getTxsByBlockHeight,
-  getContractAddress
+  getContractAddress,
+  getCaller,
+  Manifest
 };
@@ -15,9 +15,22 @@ import {
   consoleLog,
   envGetTransactionByHash,
   envGetLastHeight,
+  envCaller,
+  envGetUtxosByAddress,
+  envGetUtxoByOutpoint,
+  envGetAddressHistory,
+  envGetAddressBalance,
+  envGetWitness,
+  envGetRunestonesByBlockHeight,
+  envGetInscriptionById,
+  envGetInscriptionsByBlockHeight,
+  envGetBlockByHash,
+  envGetMempoolTransactionV2,
+  envGetMempoolTransactionV2sByAddress,
+  envGetMempoolRunestones,
 } from "./env";
 import { Value } from "assemblyscript-json/assembly/JSON";
-import { TransactionV1, TransactionV3, VinV1, VinV2, VoutV1, VoutV2 } from "./types";
+import { AddressBalance, AddressHistory, Block, TransactionV1, TransactionV3, VinV1, VinV2, VoutV1, VoutV2, Witness, Runestone, Inscription } from "./types";
 import { Network } from "./constants";
 
 export class TableOption {
@@ -59,6 +72,54 @@ export class TableOption {
   }
 }
 
+// Manifest declares which exported functions are callable as call or view,
+// owner functions must also be listed in call. Return it from an exported
+// `manifest` function using valueReturn(manifest.toJson()).
+// blockHook is called by the chain as blockHook(height, height) for every
+// indexed bitcoin block starting at blockHookStartHeight.
+export class Manifest {
+  call: string[];
+  view: string[];
+  owner: string[];
+  blockHook: string;
+  blockHookStartHeight: i64;
+
+  constructor(
+    call: string[],
+    view: string[],
+    owner: string[],
+    blockHook: string = "",
+    blockHookStartHeight: i64 = 0
+  ) {
+    this.call = call;
+    this.view = view;
+    this.owner = owner;
+    this.blockHook = blockHook;
+    this.blockHookStartHeight = blockHookStartHeight;
+  }
+
+  toJson(): string {
+    return `{"call": ${toJsonStringArray(this.call)}, "view": ${toJsonStringArray(
+      this.view
+    )}, "owner": ${toJsonStringArray(this.owner)}, "block_hook": "${
+      this.blockHook
+    }", "block_hook_start_height": ${this.blockHookStartHeight}}`;
+  }
+}
+
+function toJsonStringArray(values: string[]): string {
+  let arr = "[";
+  for (let i = 0; i < values.length; i++) {
+    arr += `"${values[i]}"`;
+    if (i < values.length - 1) {
+      arr += ",";
+    }
+  }
+  arr += "]";
+
+  return arr;
+}
+
 export class Table {
   public name: string;
   public schema: Column[];
@@ -226,6 +287,32 @@ export class TransactionV2 {
     this.vins = vins;
     this.vouts = vouts;
   }
+
+  static fromJson(jsonObj: JSON.Obj): TransactionV2 {
+    const vins: VinV2[] = [];
+    const vinsJson = jsonObj.getArr("vins");
+    if (vinsJson) {
+      for (let i = 0; i < vinsJson.valueOf().length; i++) {
+        vins.push(VinV2.fromJson(vinsJson.valueOf()[i] as JSON.Obj));
+      }
+    }
+
+    const vouts: VoutV2[] = [];
+    const voutsJson = jsonObj.getArr("vouts");
+    if (voutsJson) {
+      for (let i = 0; i < voutsJson.valueOf().length; i++) {
+        vouts.push(VoutV2.fromJson(voutsJson.valueOf()[i] as JSON.Obj));
+      }
+    }
+
+    return new TransactionV2(
+      getResultFromJson(jsonObj, "hash", "string"),
+      u32(parseInt(getResultFromJson(jsonObj, "lock_time", "int64"))),
+      u32(parseInt(getResultFromJson(jsonObj, "version", "int64"))),
+      vins,
+      vouts
+    );
+  }
 }
 
 export type TableSchema = Column[];
@@ -339,6 +426,156 @@ export function getUTXOByTransactionHash(hash: string): UTXO[] {
   return UTXOs;
 }
 
+export function getUTXOsByAddress(address: string): UTXO[] {
+  const utxosStr = ptrToString(envGetUtxosByAddress(address));
+  const jsonUTXOs = toJsonArray(utxosStr);
+  const UTXOs: UTXO[] = [];
+
+  for (let i = 0; i < jsonUTXOs.valueOf().length; i++) {
+    const jsonObj = jsonUTXOs.valueOf()[i];
+
+    if (jsonObj.isObj) {
+      UTXOs.push(UTXO.fromJson(jsonObj as JSON.Obj));
+    }
+  }
+
+  return UTXOs;
+}
+
+// returns null if the outpoint is unknown or already spent
+export function getUTXOByOutpoint(hash: string, index: u32): UTXO | null {
+  const str = ptrToString(envGetUtxoByOutpoint(hash, index));
+  if (str === "null") {
+    return null;
+  }
+
+  return UTXO.fromJson(toJson(str));
+}
+
+// latest transactions first, limit is capped at 1000
+export function getAddressHistory(address: string, offset: u32, limit: u32): AddressHistory[] {
+  const jsonHistory = toJsonArray(ptrToString(envGetAddressHistory(address, offset, limit)));
+  const history: AddressHistory[] = [];
+
+  for (let i = 0; i < jsonHistory.valueOf().length; i++) {
+    const jsonObj = jsonHistory.valueOf()[i];
+
+    if (jsonObj.isObj) {
+      history.push(AddressHistory.fromJson(jsonObj as JSON.Obj));
+    }
+  }
+
+  return history;
+}
+
+// balance at height in sats, a negative height is the last indexed height
+export function getAddressBalance(address: string, height: i64): AddressBalance {
+  return AddressBalance.fromJson(toJson(ptrToString(envGetAddressBalance(address, height))));
+}
+
+// decoded witness of a transaction input, null if the input is unknown
+export function getWitness(hash: string, index: u32): Witness | null {
+  const str = ptrToString(envGetWitness(hash, index));
+  if (str === "null") {
+    return null;
+  }
+
+  return Witness.fromJson(toJson(str));
+}
+
+// runestones and cenotaphs decoded by the indexer, in block order
+export function getRunestonesByBlockHeight(height: u64): Runestone[] {
+  const jsonRunestones = toJsonArray(ptrToString(envGetRunestonesByBlockHeight(height)));
+  const runestones: Runestone[] = [];
+
+  for (let i = 0; i < jsonRunestones.valueOf().length; i++) {
+    const jsonObj = jsonRunestones.valueOf()[i];
+
+    if (jsonObj.isObj) {
+      runestones.push(Runestone.fromJson(jsonObj as JSON.Obj));
+    }
+  }
+
+  return runestones;
+}
+
+// id is <txid>i<index>, null if the inscription is unknown
+export function getInscriptionById(id: string): Inscription | null {
+  const str = ptrToString(envGetInscriptionById(id));
+  if (str === "null") {
+    return null;
+  }
+
+  return Inscription.fromJson(toJson(str));
+}
+
+export function getInscriptionsByBlockHeight(height: u64): Inscription[] {
+  const jsonInscriptions = toJsonArray(ptrToString(envGetInscriptionsByBlockHeight(height)));
+  const inscriptions: Inscription[] = [];
+
+  for (let i = 0; i < jsonInscriptions.valueOf().length; i++) {
+    const jsonObj = jsonInscriptions.valueOf()[i];
+
+    if (jsonObj.isObj) {
+      inscriptions.push(Inscription.fromJson(jsonObj as JSON.Obj));
+    }
+  }
+
+  return inscriptions;
+}
+
+// block with hash, orphaned blocks included, null if the block is unknown
+export function getBlockByHash(hash: string): Block | null {
+  const str = ptrToString(envGetBlockByHash(hash));
+  if (str === "null") {
+    return null;
+  }
+
+  return Block.fromJson(toJson(str));
+}
+
+// unconfirmed transaction, null if it isn't in the mempool. Vin and vout block fields are empty
+export function getMempoolTransactionV2(hash: string): TransactionV2 | null {
+  const str = ptrToString(envGetMempoolTransactionV2(hash));
+  if (str === "null") {
+    return null;
+  }
+
+  return TransactionV2.fromJson(toJson(str));
+}
+
+// unconfirmed transactions spending from or paying to address
+export function getMempoolTransactionV2sByAddress(address: string): TransactionV2[] {
+  const jsonTransactions = toJsonArray(ptrToString(envGetMempoolTransactionV2sByAddress(address)));
+  const transactions: TransactionV2[] = [];
+
+  for (let i = 0; i < jsonTransactions.valueOf().length; i++) {
+    const jsonObj = jsonTransactions.valueOf()[i];
+
+    if (jsonObj.isObj) {
+      transactions.push(TransactionV2.fromJson(jsonObj as JSON.Obj));
+    }
+  }
+
+  return transactions;
+}
+
+// runestones of the unconfirmed transactions, pending runes transfers
+export function getMempoolRunestones(): Runestone[] {
+  const jsonRunestones = toJsonArray(ptrToString(envGetMempoolRunestones()));
+  const runestones: Runestone[] = [];
+
+  for (let i = 0; i < jsonRunestones.valueOf().length; i++) {
+    const jsonObj = jsonRunestones.valueOf()[i];
+
+    if (jsonObj.isObj) {
+      runestones.push(Runestone.fromJson(jsonObj as JSON.Obj));
+    }
+  }
+
+  return runestones;
+}
+
 export function getTxHashesByBlockHeight(block_height: u64): string[] {
   // Get Block
   const ptr = getBlockByHeight(block_height);
@@ -392,6 +629,11 @@ export function getContractAddress(): string {
   return ptrToString(contractAddress());
 }
 
+// getCaller returns the signer of the transaction, empty on view functions
+export function getCaller(): string {
+  return ptrToString(envCaller());
+}
+
 export function getTransactionV1sByBlockHeight(height: u64): TransactionV1[] {
   const transactions: TransactionV1[] = [];
 
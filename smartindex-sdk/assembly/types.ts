@@ -322,3 +322,464 @@ export class TransactionV3 {
     );
   }
 }
+
+export class AddressHistory {
+  address: string;
+  txHash: string;
+  blockHash: string;
+  blockHeight: u64;
+  blockTxIndex: u32;
+  received: i64;
+  sent: i64;
+
+  constructor(
+    address: string,
+    txHash: string,
+    blockHash: string,
+    blockHeight: u64,
+    blockTxIndex: u32,
+    received: i64,
+    sent: i64
+  ) {
+    this.address = address;
+    this.txHash = txHash;
+    this.blockHash = blockHash;
+    this.blockHeight = blockHeight;
+    this.blockTxIndex = blockTxIndex;
+    this.received = received;
+    this.sent = sent;
+  }
+
+  static fromJson(jsonObj: JSON.Obj): AddressHistory {
+    return new AddressHistory(
+      getResultFromJson(jsonObj, "address", "string"),
+      getResultFromJson(jsonObj, "tx_hash", "string"),
+      getResultFromJson(jsonObj, "block_hash", "string"),
+      u64(parseInt(getResultFromJson(jsonObj, "block_height", "int64"))),
+      u32(parseInt(getResultFromJson(jsonObj, "block_tx_index", "int64"))),
+      i64(parseInt(getResultFromJson(jsonObj, "received", "int64"))),
+      i64(parseInt(getResultFromJson(jsonObj, "sent", "int64")))
+    );
+  }
+}
+
+export class AddressBalance {
+  address: string;
+  height: i64;
+  received: i64;
+  sent: i64;
+  balance: i64;
+  txCount: i64;
+
+  constructor(
+    address: string,
+    height: i64,
+    received: i64,
+    sent: i64,
+    balance: i64,
+    txCount: i64
+  ) {
+    this.address = address;
+    this.height = height;
+    this.received = received;
+    this.sent = sent;
+    this.balance = balance;
+    this.txCount = txCount;
+  }
+
+  static fromJson(jsonObj: JSON.Obj): AddressBalance {
+    return new AddressBalance(
+      getResultFromJson(jsonObj, "address", "string"),
+      i64(parseInt(getResultFromJson(jsonObj, "height", "int64"))),
+      i64(parseInt(getResultFromJson(jsonObj, "received", "int64"))),
+      i64(parseInt(getResultFromJson(jsonObj, "sent", "int64"))),
+      i64(parseInt(getResultFromJson(jsonObj, "balance", "int64"))),
+      i64(parseInt(getResultFromJson(jsonObj, "tx_count", "int64")))
+    );
+  }
+}
+
+export class Block {
+  hash: string;
+  height: u64;
+  isOrphan: bool;
+  previousBlock: string;
+  version: i32;
+  nonce: u32;
+  timestamp: u32;
+  bits: string;
+  merkleRoot: string;
+
+  constructor(
+    hash: string,
+    height: u64,
+    isOrphan: bool,
+    previousBlock: string,
+    version: i32,
+    nonce: u32,
+    timestamp: u32,
+    bits: string,
+    merkleRoot: string
+  ) {
+    this.hash = hash;
+    this.height = height;
+    this.isOrphan = isOrphan;
+    this.previousBlock = previousBlock;
+    this.version = version;
+    this.nonce = nonce;
+    this.timestamp = timestamp;
+    this.bits = bits;
+    this.merkleRoot = merkleRoot;
+  }
+
+  static fromJson(jsonObj: JSON.Obj): Block {
+    const isOrphan = jsonObj.getBool("is_orphan");
+
+    return new Block(
+      getResultFromJson(jsonObj, "hash", "string"),
+      u64(parseInt(getResultFromJson(jsonObj, "height", "int64"))),
+      isOrphan != null && isOrphan.valueOf(),
+      getResultFromJson(jsonObj, "previous_block", "string"),
+      i32(parseInt(getResultFromJson(jsonObj, "version", "int64"))),
+      u32(parseInt(getResultFromJson(jsonObj, "nonce", "int64"))),
+      u32(parseInt(getResultFromJson(jsonObj, "timestamp", "int64"))),
+      getResultFromJson(jsonObj, "bits", "string"),
+      getResultFromJson(jsonObj, "merkle_root", "string")
+    );
+  }
+}
+
+export class Witness {
+  txHash: string;
+  txIndex: u32;
+  stack: string[];
+  annex: string;
+  tapscript: string;
+  tapscriptAsm: string[];
+  controlBlock: string;
+  leafVersion: u8;
+
+  constructor(
+    txHash: string,
+    txIndex: u32,
+    stack: string[],
+    annex: string,
+    tapscript: string,
+    tapscriptAsm: string[],
+    controlBlock: string,
+    leafVersion: u8
+  ) {
+    this.txHash = txHash;
+    this.txIndex = txIndex;
+    this.stack = stack;
+    this.annex = annex;
+    this.tapscript = tapscript;
+    this.tapscriptAsm = tapscriptAsm;
+    this.controlBlock = controlBlock;
+    this.leafVersion = leafVersion;
+  }
+
+  // tapscript fields are empty unless the input is a taproot script path spend
+  isScriptPath(): bool {
+    return this.controlBlock != "";
+  }
+
+  static fromJson(jsonObj: JSON.Obj): Witness {
+    return new Witness(
+      getResultFromJson(jsonObj, "tx_hash", "string"),
+      u32(parseInt(getResultFromJson(jsonObj, "tx_index", "int64"))),
+      getStringsFromJson(jsonObj, "stack"),
+      getResultFromJson(jsonObj, "annex", "string"),
+      getResultFromJson(jsonObj, "tapscript", "string"),
+      getStringsFromJson(jsonObj, "tapscript_asm"),
+      getResultFromJson(jsonObj, "control_block", "string"),
+      u8(parseInt(getResultFromJson(jsonObj, "leaf_version", "int64")))
+    );
+  }
+}
+
+function getStringsFromJson(jsonObj: JSON.Obj, fieldName: string): string[] {
+  const result: string[] = [];
+  const arr: JSON.Arr | null = jsonObj.getArr(fieldName);
+  if (arr == null) {
+    return result;
+  }
+
+  for (let i = 0; i < arr.valueOf().length; i++) {
+    const item = arr.valueOf()[i];
+    if (item.isString) {
+      result.push((item as JSON.Str).valueOf());
+    }
+  }
+
+  return result;
+}
+
+export class RuneId {
+  block: u64;
+  tx: u32;
+
+  constructor(block: u64, tx: u32) {
+    this.block = block;
+    this.tx = tx;
+  }
+
+  toString(): string {
+    return this.block.toString() + ":" + this.tx.toString();
+  }
+
+  static fromJson(jsonObj: JSON.Obj): RuneId {
+    return new RuneId(
+      u64(parseInt(getResultFromJson(jsonObj, "block", "int64"))),
+      u32(parseInt(getResultFromJson(jsonObj, "tx", "int64")))
+    );
+  }
+}
+
+// amounts are u128 decimal strings
+export class Edict {
+  id: RuneId;
+  amount: string;
+  output: u32;
+
+  constructor(id: RuneId, amount: string, output: u32) {
+    this.id = id;
+    this.amount = amount;
+    this.output = output;
+  }
+
+  static fromJson(jsonObj: JSON.Obj): Edict {
+    return new Edict(
+      RuneId.fromJson(jsonObj.getObj("id") as JSON.Obj),
+      getResultFromJson(jsonObj, "amount", "string"),
+      u32(parseInt(getResultFromJson(jsonObj, "output", "int64")))
+    );
+  }
+}
+
+// unset amounts are empty strings and unset heights and offsets are -1
+export class Terms {
+  amount: string;
+  cap: string;
+  heightStart: i64;
+  heightEnd: i64;
+  offsetStart: i64;
+  offsetEnd: i64;
+
+  constructor(
+    amount: string,
+    cap: string,
+    heightStart: i64,
+    heightEnd: i64,
+    offsetStart: i64,
+    offsetEnd: i64
+  ) {
+    this.amount = amount;
+    this.cap = cap;
+    this.heightStart = heightStart;
+    this.heightEnd = heightEnd;
+    this.offsetStart = offsetStart;
+    this.offsetEnd = offsetEnd;
+  }
+
+  static fromJson(jsonObj: JSON.Obj): Terms {
+    return new Terms(
+      getResultFromJson(jsonObj, "amount", "string"),
+      getResultFromJson(jsonObj, "cap", "string"),
+      getOptionalIntFromJson(jsonObj, "height_start"),
+      getOptionalIntFromJson(jsonObj, "height_end"),
+      getOptionalIntFromJson(jsonObj, "offset_start"),
+      getOptionalIntFromJson(jsonObj, "offset_end")
+    );
+  }
+}
+
+// an empty rune name means the rune gets a reserved name
+export class Etching {
+  divisibility: u8;
+  premine: string;
+  rune: string;
+  spacedRune: string;
+  spacers: u32;
+  symbol: string;
+  terms: Terms | null;
+  turbo: bool;
+
+  constructor(
+    divisibility: u8,
+    premine: string,
+    rune: string,
+    spacedRune: string,
+    spacers: u32,
+    symbol: string,
+    terms: Terms | null,
+    turbo: bool
+  ) {
+    this.divisibility = divisibility;
+    this.premine = premine;
+    this.rune = rune;
+    this.spacedRune = spacedRune;
+    this.spacers = spacers;
+    this.symbol = symbol;
+    this.terms = terms;
+    this.turbo = turbo;
+  }
+
+  static fromJson(jsonObj: JSON.Obj): Etching {
+    const terms = jsonObj.getObj("terms");
+    const turbo = jsonObj.getBool("turbo");
+
+    return new Etching(
+      u8(max<i64>(getOptionalIntFromJson(jsonObj, "divisibility"), 0)),
+      getResultFromJson(jsonObj, "premine", "string"),
+      getResultFromJson(jsonObj, "rune", "string"),
+      getResultFromJson(jsonObj, "spaced_rune", "string"),
+      u32(max<i64>(getOptionalIntFromJson(jsonObj, "spacers"), 0)),
+      getResultFromJson(jsonObj, "symbol", "string"),
+      terms != null ? Terms.fromJson(terms) : null,
+      turbo != null && turbo.valueOf()
+    );
+  }
+}
+
+// a cenotaph has no edicts, its etching only has the rune name and the runes of its inputs are burned
+export class Runestone {
+  txHash: string;
+  blockHeight: u64;
+  blockTxIndex: u32;
+  edicts: Edict[];
+  etching: Etching | null;
+  mint: RuneId | null;
+  pointer: i64;
+  cenotaph: bool;
+  flaw: string;
+
+  constructor(
+    txHash: string,
+    blockHeight: u64,
+    blockTxIndex: u32,
+    edicts: Edict[],
+    etching: Etching | null,
+    mint: RuneId | null,
+    pointer: i64,
+    cenotaph: bool,
+    flaw: string
+  ) {
+    this.txHash = txHash;
+    this.blockHeight = blockHeight;
+    this.blockTxIndex = blockTxIndex;
+    this.edicts = edicts;
+    this.etching = etching;
+    this.mint = mint;
+    this.pointer = pointer;
+    this.cenotaph = cenotaph;
+    this.flaw = flaw;
+  }
+
+  static fromJson(jsonObj: JSON.Obj): Runestone {
+    const edicts: Edict[] = [];
+    const jsonEdicts = jsonObj.getArr("edicts");
+    if (jsonEdicts != null) {
+      for (let i = 0; i < jsonEdicts.valueOf().length; i++) {
+        const jsonEdict = jsonEdicts.valueOf()[i];
+        if (jsonEdict.isObj) {
+          edicts.push(Edict.fromJson(jsonEdict as JSON.Obj));
+        }
+      }
+    }
+
+    const etching = jsonObj.getObj("etching");
+    const mint = jsonObj.getObj("mint");
+    const cenotaph = jsonObj.getBool("cenotaph");
+
+    return new Runestone(
+      getResultFromJson(jsonObj, "tx_hash", "string"),
+      u64(parseInt(getResultFromJson(jsonObj, "block_height", "int64"))),
+      u32(parseInt(getResultFromJson(jsonObj, "block_tx_index", "int64"))),
+      edicts,
+      etching != null ? Etching.fromJson(etching) : null,
+      mint != null ? RuneId.fromJson(mint) : null,
+      getOptionalIntFromJson(jsonObj, "pointer"),
+      cenotaph != null && cenotaph.valueOf(),
+      getResultFromJson(jsonObj, "flaw", "string")
+    );
+  }
+}
+
+function getOptionalIntFromJson(jsonObj: JSON.Obj, fieldName: string): i64 {
+  const value: JSON.Integer | null = jsonObj.getInteger(fieldName);
+  if (value == null) {
+    return -1;
+  }
+
+  return value.valueOf();
+}
+
+// binary fields are hex encoded, a missing pointer is -1
+export class Inscription {
+  id: string;
+  txHash: string;
+  blockHeight: u64;
+  blockTxIndex: u32;
+  input: u32;
+  offset: u32;
+  contentType: string;
+  contentEncoding: string;
+  body: string;
+  parents: string[];
+  delegate: string;
+  pointer: i64;
+  metaprotocol: string;
+  metadata: string;
+
+  constructor(
+    id: string,
+    txHash: string,
+    blockHeight: u64,
+    blockTxIndex: u32,
+    input: u32,
+    offset: u32,
+    contentType: string,
+    contentEncoding: string,
+    body: string,
+    parents: string[],
+    delegate: string,
+    pointer: i64,
+    metaprotocol: string,
+    metadata: string
+  ) {
+    this.id = id;
+    this.txHash = txHash;
+    this.blockHeight = blockHeight;
+    this.blockTxIndex = blockTxIndex;
+    this.input = input;
+    this.offset = offset;
+    this.contentType = contentType;
+    this.contentEncoding = contentEncoding;
+    this.body = body;
+    this.parents = parents;
+    this.delegate = delegate;
+    this.pointer = pointer;
+    this.metaprotocol = metaprotocol;
+    this.metadata = metadata;
+  }
+
+  static fromJson(jsonObj: JSON.Obj): Inscription {
+    return new Inscription(
+      getResultFromJson(jsonObj, "id", "string"),
+      getResultFromJson(jsonObj, "tx_hash", "string"),
+      u64(parseInt(getResultFromJson(jsonObj, "block_height", "int64"))),
+      u32(parseInt(getResultFromJson(jsonObj, "block_tx_index", "int64"))),
+      u32(parseInt(getResultFromJson(jsonObj, "input", "int64"))),
+      u32(parseInt(getResultFromJson(jsonObj, "offset", "int64"))),
+      getResultFromJson(jsonObj, "content_type", "string"),
+      getResultFromJson(jsonObj, "content_encoding", "string"),
+      getResultFromJson(jsonObj, "body", "string"),
+      getStringsFromJson(jsonObj, "parents"),
+      getResultFromJson(jsonObj, "delegate", "string"),
+      getOptionalIntFromJson(jsonObj, "pointer"),
+      getResultFromJson(jsonObj, "metaprotocol", "string"),
+      getResultFromJson(jsonObj, "metadata", "string")
+    );
+  }
+}
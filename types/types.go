@@ -1,16 +1,20 @@
 package types
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"eastnode/utils"
 	"encoding/hex"
+	"errors"
+	"fmt"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/cbergoon/merkletree"
 	"github.com/dustinxie/ecc"
+	"github.com/near/borsh-go"
 )
 
-// Kind: ["call", "view", "deploy", "genesis"]
+// Kind: ["call", "view", "deploy", "genesis", "transfer", "transfer_ownership"]
 // FunctionName: "any"
 // Args: []string
 type Action struct {
@@ -30,13 +34,23 @@ type RpcReply struct {
 	BlockHash   string `json:"block_hash"`
 	BlockHeight uint64 `json:"block_height"`
 	Result      []byte `json:"result"`
+	// whether the bitcoin indexer follows the tip, results may be stale otherwise
+	IndexerSynced bool `json:"indexer_synced"`
 }
 
 // ID hex, Signature hex, Transaction hex
+// Cosignatures are extra signatures over the same transaction, e.g. for m-of-n smart index owners, the ID covers them
 type SignedTransaction struct {
-	ID          string `json:"id"`
-	Signature   string `json:"signed"`
-	Transaction string `json:"transaction"`
+	ID           string        `json:"id"`
+	Signature    string        `json:"signed"`
+	Transaction  string        `json:"transaction"`
+	Cosignatures []Cosignature `json:"cosignatures"`
+}
+
+// Signer hex public key, Signature hex
+type Cosignature struct {
+	Signer    string `json:"signer"`
+	Signature string `json:"signed"`
 }
 
 type JsonArray struct {
@@ -44,11 +58,12 @@ type JsonArray struct {
 }
 
 func (st *SignedTransaction) Unpack() Transaction {
-	txUnpacked := new(Transaction)
-
-	utils.DecodeHexAndBorshDeserialize(txUnpacked, st.Transaction)
+	txUnpacked, err := DecodeTransaction(st.Transaction)
+	if err != nil {
+		panic(err)
+	}
 
-	return *txUnpacked
+	return txUnpacked
 }
 
 func (st *SignedTransaction) IsValid() bool {
@@ -69,25 +84,126 @@ func (st *SignedTransaction) IsValid() bool {
 		panic(err)
 	}
 
-	txBytes, _ := hex.DecodeString(st.Transaction)
-	hashedMsg := sha256.Sum256(txBytes)
-	verified := ecc.VerifyBytes(pubKey.ToECDSA(), hashedMsg[:], sigBytes, ecc.Normal)
-
-	hashedSignature := utils.SHA256([]byte(st.Signature))
+	verified := verifyTransactionSignature(pubKey, sigBytes, st.Transaction)
 
-	if hashedSignature != st.ID {
+	if st.Hash() != st.ID {
 		panic("TxId invalid")
 	}
 
 	return verified
 }
 
+// Hash is the ID of the transaction, the hash of the signature followed by the cosigners and their signatures.
+// Cosignatures are covered so a relayer can't strip or replace them without changing the ID
+func (st *SignedTransaction) Hash() string {
+	signatures := st.Signature
+	for _, cosignature := range st.Cosignatures {
+		signatures += ":" + cosignature.Signer + ":" + cosignature.Signature
+	}
+
+	return utils.SHA256([]byte(signatures))
+}
+
+// CosignaturesValid returns false if any cosignature does not sign the transaction
+func (st *SignedTransaction) CosignaturesValid() bool {
+	for _, cosignature := range st.Cosignatures {
+		if !cosignature.IsValid(st.Transaction) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Signers returns the transaction signer followed by every valid cosigner
+func (st *SignedTransaction) Signers() []string {
+	signers := []string{st.Unpack().Signer}
+
+	for _, cosignature := range st.Cosignatures {
+		if cosignature.IsValid(st.Transaction) {
+			signers = append(signers, cosignature.Signer)
+		}
+	}
+
+	return signers
+}
+
+func (cs *Cosignature) IsValid(transaction string) bool {
+	pubKeyBytes, err := hex.DecodeString(cs.Signer)
+	if err != nil {
+		return false
+	}
+
+	pubKey, err := btcec.ParsePubKey(pubKeyBytes)
+	if err != nil {
+		return false
+	}
+
+	sigBytes, err := hex.DecodeString(cs.Signature)
+	if err != nil {
+		return false
+	}
+
+	return verifyTransactionSignature(pubKey, sigBytes, transaction)
+}
+
+func verifyTransactionSignature(pubKey *btcec.PublicKey, sigBytes []byte, transaction string) bool {
+	txBytes, _ := hex.DecodeString(transaction)
+	hashedMsg := sha256.Sum256(txBytes)
+
+	return ecc.VerifyBytes(pubKey.ToECDSA(), hashedMsg[:], sigBytes, ecc.Normal)
+}
+
 // Signer str, Receiver str, Actions hex
+// ChainID binds the transaction to a single chain to prevent replays across networks
+// ExpiryHeight is the last block height the transaction can be included at, 0 never expires
 type Transaction struct {
-	Nonce    uint64 `json:"nonce"`
-	Signer   string `json:"signer"`
-	Receiver string `json:"receiver"`
-	Actions  string `json:"actions"`
+	Nonce        uint64 `json:"nonce"`
+	Signer       string `json:"signer"`
+	Receiver     string `json:"receiver"`
+	Actions      string `json:"actions"`
+	ChainID      string `json:"chain_id"`
+	ExpiryHeight uint64 `json:"expiry_height"`
+}
+
+// legacyTransaction is the layout of transactions signed before ChainID and ExpiryHeight were added
+type legacyTransaction struct {
+	Nonce    uint64
+	Signer   string
+	Receiver string
+	Actions  string
+}
+
+// DecodeTransaction decodes a hex borsh transaction of the current or the legacy layout,
+// legacy transactions have no ChainID so they are only valid as already stored ones
+func DecodeTransaction(s string) (Transaction, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	// borsh ignores trailing bytes, so a layout only matches if it encodes back to the same bytes
+	tx := new(Transaction)
+	if err := borsh.Deserialize(tx, b); err == nil {
+		if encoded, err := borsh.Serialize(*tx); err == nil && bytes.Equal(encoded, b) {
+			return *tx, nil
+		}
+	}
+
+	legacyTx := new(legacyTransaction)
+	if err := borsh.Deserialize(legacyTx, b); err != nil {
+		return Transaction{}, fmt.Errorf("invalid transaction: %w", err)
+	}
+	if encoded, err := borsh.Serialize(*legacyTx); err != nil || !bytes.Equal(encoded, b) {
+		return Transaction{}, errors.New("invalid transaction layout")
+	}
+
+	return Transaction{
+		Nonce:    legacyTx.Nonce,
+		Signer:   legacyTx.Signer,
+		Receiver: legacyTx.Receiver,
+		Actions:  legacyTx.Actions,
+	}, nil
 }
 
 // func (t *Transaction) serialize() []byte {
@@ -114,10 +230,44 @@ type BitcoinServerQuery struct {
 	Params []interface{} `json:"params"`
 }
 
+type AddressHistoryArgs struct {
+	Address string `json:"address"`
+	Offset  int    `json:"offset"`
+	Limit   int    `json:"limit"`
+}
+
+// Height -1 is the last indexed height
+type AddressBalanceArgs struct {
+	Address string `json:"address"`
+	Height  int64  `json:"height"`
+}
+
+// Id is <txid>i<index>
+type InscriptionArgs struct {
+	Id string `json:"id"`
+}
+
+type InscriptionsByBlockHeightArgs struct {
+	Height uint64 `json:"height"`
+}
+
+type MempoolTransactionArgs struct {
+	Hash string `json:"hash"`
+}
+
+type MempoolTransactionsByAddressArgs struct {
+	Address string `json:"address"`
+}
+
+type MempoolRunestonesArgs struct{}
+
+type SyncStatusArgs struct{}
+
 type ServerQueryReply struct {
-	BlockHash   string `json:"block_hash"`
-	BlockHeight uint64 `json:"block_height"`
-	Result      string `json:"result"`
+	BlockHash     string `json:"block_hash"`
+	BlockHeight   uint64 `json:"block_height"`
+	Result        string `json:"result"`
+	IndexerSynced bool   `json:"indexer_synced"`
 }
 
 type MerkleTreeContent struct {
@@ -137,6 +287,7 @@ func (t MerkleTreeContent) Equals(other merkletree.Content) (bool, error) {
 	return t.Value == other.(MerkleTreeContent).Value, nil
 }
 
+// BitcoinHash is the last bitcoin block the block hooks ran up to, empty if there was none
 type BlockHeader struct {
 	ChainID     string
 	BitcoinHash string
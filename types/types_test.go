@@ -0,0 +1,102 @@
+package types
+
+import (
+	"crypto/sha256"
+	"eastnode/utils"
+	"encoding/hex"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/dustinxie/ecc"
+	"github.com/near/borsh-go"
+)
+
+func TestDecodeTransaction(t *testing.T) {
+	tx := Transaction{Nonce: 2, Signer: "signer", Receiver: "receiver", Actions: "00", ChainID: "Eastblue-regtest", ExpiryHeight: 10}
+	packed, err := borsh.Serialize(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeTransaction(hex.EncodeToString(packed))
+	if err != nil {
+		t.Fatalf("Failed to decode transaction: %v", err)
+	}
+	if decoded != tx {
+		t.Errorf("Decoded transaction incorrect %+v", decoded)
+	}
+
+	// signed before the chain id and expiry height were added
+	legacyPacked, err := borsh.Serialize(legacyTransaction{Nonce: 1, Signer: "signer", Receiver: "receiver", Actions: "00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err = DecodeTransaction(hex.EncodeToString(legacyPacked))
+	if err != nil {
+		t.Fatalf("Failed to decode legacy transaction: %v", err)
+	}
+	if decoded.Nonce != 1 || decoded.Signer != "signer" || decoded.Receiver != "receiver" || decoded.Actions != "00" || decoded.ChainID != "" || decoded.ExpiryHeight != 0 {
+		t.Errorf("Decoded legacy transaction incorrect %+v", decoded)
+	}
+
+	if _, err := DecodeTransaction(hex.EncodeToString(append(legacyPacked, 1))); err == nil {
+		t.Error("Transaction with trailing bytes should fail")
+	}
+	if _, err := DecodeTransaction("zz"); err == nil {
+		t.Error("Invalid hex should fail")
+	}
+}
+
+func signTestTransaction(t *testing.T, privateKey *btcec.PrivateKey, packed string) string {
+	packedBytes, _ := hex.DecodeString(packed)
+	hash := sha256.Sum256(packedBytes)
+
+	signature, err := ecc.SignBytes(privateKey.ToECDSA(), hash[:], ecc.Normal)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hex.EncodeToString(signature)
+}
+
+func TestSignedTransaction_Hash(t *testing.T) {
+	signerKey, _ := btcec.NewPrivateKey()
+	cosignerKey, _ := btcec.NewPrivateKey()
+
+	tx := Transaction{Signer: hex.EncodeToString(signerKey.PubKey().SerializeCompressed()), ChainID: "Eastblue-regtest"}
+	packed, err := borsh.Serialize(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedTx := SignedTransaction{Transaction: hex.EncodeToString(packed)}
+	signedTx.Signature = signTestTransaction(t, signerKey, signedTx.Transaction)
+
+	// without cosignatures the ID stays the hash of the signature
+	if signedTx.Hash() != utils.SHA256([]byte(signedTx.Signature)) {
+		t.Errorf("Hash without cosignatures incorrect %s", signedTx.Hash())
+	}
+
+	signedTx.Cosignatures = []Cosignature{{
+		Signer:    hex.EncodeToString(cosignerKey.PubKey().SerializeCompressed()),
+		Signature: signTestTransaction(t, cosignerKey, signedTx.Transaction),
+	}}
+	signedTx.ID = signedTx.Hash()
+	if !signedTx.IsValid() || !signedTx.CosignaturesValid() || len(signedTx.Signers()) != 2 {
+		t.Errorf("Cosigned transaction should be valid")
+	}
+
+	stripped := signedTx
+	stripped.Cosignatures = nil
+	if stripped.Hash() == signedTx.ID {
+		t.Error("Stripping the cosignatures should change the ID")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Transaction with stripped cosignatures should not match its ID")
+		}
+	}()
+	stripped.IsValid()
+}
@@ -61,6 +61,8 @@ func (s *Store) InitChainDb(dbPath string) {
 		}
 	}
 
+	s.migrateChainDb()
+
 	log.Println("[+] Chain database instance is running")
 
 	kv, err := bolt.Open(utils.Cwd()+dbPath+"/chain.db", 0600, nil)
@@ -92,6 +94,35 @@ func (s *Store) InitChainDb(dbPath string) {
 	s.KV = kv
 }
 
+// tables added after the initial core schema, also created on existing databases
+func (s *Store) migrateChainDb() {
+	_, err := s.Instance.Exec(`
+		CREATE TABLE IF NOT EXISTS smart_index_owners (
+			smart_index_address VARCHAR(255),
+			owners JSON,
+			threshold INT,
+			primary key(smart_index_address)
+		);
+		CREATE TABLE IF NOT EXISTS balances (
+			account VARCHAR(255),
+			balance BIGINT UNSIGNED,
+			primary key(account)
+		);
+		CREATE TABLE IF NOT EXISTS smart_index_hooks (
+			smart_index_address VARCHAR(255),
+			function_name VARCHAR(255),
+			last_height BIGINT,
+			failures INT DEFAULT 0,
+			primary key(smart_index_address)
+		);
+		CALL DOLT_COMMIT('--allow-empty', '-Am', 'migrate core schema');
+	`)
+
+	if err != nil {
+		panic(err)
+	}
+}
+
 func (s *Store) Close() error {
 	if err := s.Instance.Close(); err != nil {
 		return err